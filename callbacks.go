@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackDataLimit — максимальная длина данных inline-кнопки в байтах, больше Telegram не принимает.
+const callbackDataLimit = 64
+
+// Разделы inline-кнопок. По разделу handleCallback выбирает обработчик из callbackRoutes.
+const (
+	callbackMenu    = "menu"    // Главное меню и переходы между режимами
+	callbackHistory = "history" // История раскладов
+	callbackCards   = "cards"   // Справочник значений карт
+	callbackTariff  = "tariff"  // Покупка тарифов
+	callbackGuide   = "guide"   // Инструкция
+)
+
+// staleButtonText — ответ на кнопку, которую бот уже не понимает (например, после обновления).
+const staleButtonText = "Кнопка устарела, откройте меню заново."
+
+// CallbackData — данные inline-кнопки вида "<раздел>:<действие>:<аргументы через двоеточие>".
+// Обработчики различают кнопки по разделу и действию, а не по подписи, поэтому подписи можно менять свободно.
+type CallbackData struct {
+	Section string
+	Action  string
+	Args    []string
+}
+
+// Функция newCallbackData создаёт данные кнопки; аргументы записываются через fmt.Sprint.
+func newCallbackData(section, action string, args ...any) CallbackData {
+	data := CallbackData{Section: section, Action: action}
+	for _, arg := range args {
+		data.Args = append(data.Args, fmt.Sprint(arg))
+	}
+	return data
+}
+
+// Функция parseCallbackData разбирает данные нажатой кнопки.
+func parseCallbackData(s string) (CallbackData, error) {
+	fields := strings.Split(s, ":")
+	if len(fields) < 2 || fields[0] == "" || fields[1] == "" {
+		return CallbackData{}, fmt.Errorf("некорректные данные кнопки %q", s)
+	}
+	return CallbackData{Section: fields[0], Action: fields[1], Args: fields[2:]}, nil
+}
+
+// String записывает данные кнопки в том виде, в котором они передаются Telegram.
+func (d CallbackData) String() string {
+	return strings.Join(append([]string{d.Section, d.Action}, d.Args...), ":")
+}
+
+// Arg возвращает аргумент с номером i или пустую строку, если его нет.
+func (d CallbackData) Arg(i int) string {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return ""
+}
+
+// Int возвращает аргумент с номером i как число.
+func (d CallbackData) Int(i int) (int, error) {
+	if i >= len(d.Args) {
+		return 0, fmt.Errorf("нет аргумента %d в данных кнопки %q", i, d)
+	}
+	return strconv.Atoi(d.Args[i])
+}
+
+// Функция callbackButton создаёт inline-кнопку с подписью label и данными data.
+func callbackButton(label string, data CallbackData) tgbotapi.InlineKeyboardButton {
+	value := data.String()
+	if len(value) > callbackDataLimit {
+		log.Printf("Данные кнопки %q длиннее %d байт, Telegram её не примет", value, callbackDataLimit)
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(label, value)
+}
+
+// callbackHandler обрабатывает нажатие inline-кнопки своего раздела.
+// Возвращает текст короткого уведомления для пользователя (пусто — без уведомления).
+type callbackHandler func(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string
+
+// callbackRoutes — обработчики inline-кнопок по разделам.
+var callbackRoutes = map[string]callbackHandler{
+	callbackMenu:    handleMenuCallback,
+	callbackHistory: handleHistoryCallback,
+	callbackCards:   handleEncyclopediaCallback,
+	callbackTariff:  handleTariffCallback,
+	callbackGuide:   handleGuideCallback,
+}
+
+// Функция handleCallback обрабатывает нажатия на inline-кнопки: разбирает данные кнопки,
+// вызывает обработчик раздела с сессией чата и отвечает Telegram на нажатие.
+func handleCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery) {
+	// Telegram ждёт ответа на каждое нажатие, иначе кнопка «зависает» с часиками.
+	var answer string
+	defer func() {
+		if _, err := bot.Request(tgbotapi.NewCallback(query.ID, answer)); err != nil {
+			log.Printf("Ошибка ответа на нажатие кнопки: %v", err)
+		}
+	}()
+	if query.Message == nil || query.From == nil {
+		answer = staleButtonText
+		return
+	}
+
+	data, err := parseCallbackData(query.Data)
+	if err != nil {
+		log.Printf("Неизвестная inline-кнопка: %v", err)
+		answer = staleButtonText
+		return
+	}
+	handler, ok := callbackRoutes[data.Section]
+	if !ok {
+		log.Printf("Неизвестная inline-кнопка: %q", query.Data)
+		answer = staleButtonText
+		return
+	}
+
+	// Кнопки могут менять состояние чата, поэтому работают с той же сессией, что и сообщения.
+	chatID := query.Message.Chat.ID
+	session, exists := sessions.Get(chatID)
+	if !exists {
+		session.State = "main"
+	}
+	defer func() {
+		if err := sessions.Set(chatID, session); err != nil {
+			log.Printf("Ошибка сохранения сессии чата %d: %v", chatID, err)
+		}
+	}()
+	answer = handler(bot, query, &session, data)
+}
+
+// Функция unknownCallback записывает в лог кнопку, которую обработчик раздела не понял,
+// и возвращает ответ пользователю.
+func unknownCallback(query *tgbotapi.CallbackQuery) string {
+	log.Printf("Неизвестная inline-кнопка: %q", query.Data)
+	return staleButtonText
+}
+
+// Функция editInlineMessage заменяет текст и inline-кнопки сообщения, на котором нажали кнопку.
+func editInlineMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, text string, markup *tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	edit.ReplyMarkup = markup
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Ошибка обновления сообщения %d: %v", message.MessageID, err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	_ "golang.org/x/image/webp"
+)
+
+// telegramMediaGroupLimit — сколько фотографий Telegram принимает в одной медиагруппе.
+const telegramMediaGroupLimit = 10
+
+// cardImageExtensions — расширения, по которым поле image карты считается путём к файлу.
+var cardImageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true}
+
+// Функция isCardImageURL сообщает, задано ли изображение карты ссылкой.
+func isCardImageURL(image string) bool {
+	return strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://")
+}
+
+// Функция isCardImagePath сообщает, задано ли изображение карты путём к файлу на диске.
+// Всё, что не ссылка и не путь, считается file_id, уже загруженным в Telegram.
+func isCardImagePath(image string) bool {
+	return !isCardImageURL(image) && cardImageExtensions[strings.ToLower(filepath.Ext(image))]
+}
+
+// CardImageCache запоминает file_id, который Telegram присвоил загруженному изображению карты,
+// чтобы при следующих раскладах не загружать файл повторно.
+// Ключ — путь к файлу (с пометкой "#reversed" для повёрнутой копии):
+// если в колоде указать другой файл, он будет загружен заново.
+type CardImageCache struct {
+	mu      sync.RWMutex
+	path    string            // Файл, в котором хранится кэш (пусто — только в памяти)
+	fileIDs map[string]string // Путь к изображению → file_id
+}
+
+// Функция NewCardImageCache открывает кэш file_id в файле path.
+func NewCardImageCache(path string) (*CardImageCache, error) {
+	c := &CardImageCache{path: path, fileIDs: make(map[string]string)}
+	if path == "" {
+		return c, nil
+	}
+
+	if err := loadJSONFile(path, &c.fileIDs); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Функция cardImageKey возвращает ключ кэша для изображения карты в нужном положении:
+// перевёрнутая карта загружается в Telegram отдельным, повёрнутым файлом.
+func cardImageKey(card DrawnCard) string {
+	if card.Reversed && isCardImagePath(card.Card.Image) {
+		return card.Card.Image + "#reversed"
+	}
+	return card.Card.Image
+}
+
+// Media возвращает, как передать изображение карты в Telegram:
+// по file_id из кэша или из колоды, по ссылке или загрузкой файла.
+// Файл перевёрнутой карты загружается повёрнутым на 180 градусов. Ссылку и file_id
+// повернуть нельзя, поэтому положение такой карты видно только из подписи.
+func (c *CardImageCache) Media(card DrawnCard) tgbotapi.RequestFileData {
+	source := card.Card.Image
+	switch {
+	case isCardImageURL(source):
+		return tgbotapi.FileURL(source)
+	case isCardImagePath(source):
+		c.mu.RLock()
+		fileID, ok := c.fileIDs[cardImageKey(card)]
+		c.mu.RUnlock()
+		if ok {
+			return tgbotapi.FileID(fileID)
+		}
+		if card.Reversed {
+			data, err := reversedCardImage(source)
+			if err == nil {
+				return tgbotapi.FileBytes{Name: card.Card.ID + "-reversed.png", Bytes: data}
+			}
+			log.Printf("Ошибка поворота изображения карты %s: %v", card.Card.ID, err)
+		}
+		return tgbotapi.FilePath(source)
+	default:
+		return tgbotapi.FileID(source)
+	}
+}
+
+// Функция reversedCardImage читает изображение карты из файла path
+// и возвращает его повёрнутым на 180 градусов в формате PNG.
+func reversedCardImage(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	src, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rotate180(src)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Remember сохраняет file_id файла, загруженного под ключом key (см. cardImageKey).
+func (c *CardImageCache) Remember(key, fileID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fileIDs[key] == fileID {
+		return nil
+	}
+	c.fileIDs[key] = fileID
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.fileIDs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации кэша изображений: %v", err)
+	}
+	return writeFileAtomic(c.path, data)
+}
+
+// Глобальный кэш file_id изображений карт (переменная CARD_IMAGE_CACHE).
+var cardImages *CardImageCache
+
+// Функция sendCardImages отправляет изображения вытянутых карт медиагруппой
+// (по одной группе на каждые десять карт). Карты без изображения пропускаются,
+// перевёрнутые карты показываются вверх ногами, а положение каждой карты указано в подписи.
+// После отправки file_id загруженных файлов запоминаются в кэше. Возвращает ID отправленных сообщений.
+func sendCardImages(bot *tgbotapi.BotAPI, chatID int64, cards []DrawnCard) []int {
+	var withImages []DrawnCard
+	for _, card := range cards {
+		if card.Card.Image != "" {
+			withImages = append(withImages, card)
+		}
+	}
+
+	var messageIDs []int
+	for start := 0; start < len(withImages); start += telegramMediaGroupLimit {
+		batch := withImages[start:min(start+telegramMediaGroupLimit, len(withImages))]
+
+		var sent []tgbotapi.Message
+		var err error
+		if len(batch) == 1 {
+			// Медиагруппа должна содержать минимум две фотографии, одну отправляем обычным фото
+			var msg tgbotapi.Message
+			msg, err = bot.Send(cardPhoto(chatID, batch[0], batch[0].Title()))
+			sent = []tgbotapi.Message{msg}
+		} else {
+			media := make([]interface{}, len(batch))
+			for i, card := range batch {
+				photo := tgbotapi.NewInputMediaPhoto(cardImages.Media(card))
+				photo.Caption = card.Title()
+				media[i] = photo
+			}
+			sent, err = bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+		}
+		if err != nil {
+			log.Printf("Ошибка отправки изображений карт: %v", err)
+			continue
+		}
+
+		for i, msg := range sent {
+			messageIDs = append(messageIDs, msg.MessageID)
+			if i < len(batch) {
+				rememberCardPhoto(batch[i], msg)
+			}
+		}
+	}
+	return messageIDs
+}
+
+// Функция cardPhoto готовит отправку изображения одной карты с подписью caption.
+func cardPhoto(chatID int64, card DrawnCard, caption string) tgbotapi.PhotoConfig {
+	photo := tgbotapi.NewPhoto(chatID, cardImages.Media(card))
+	photo.Caption = caption
+	return photo
+}
+
+// Функция sendCardPhoto отправляет изображение одной карты с подписью caption
+// и запоминает file_id загруженного файла. Возвращает ID сообщения (0, если отправить не удалось).
+func sendCardPhoto(bot *tgbotapi.BotAPI, chatID int64, card DrawnCard, caption string) int {
+	msg, err := bot.Send(cardPhoto(chatID, card, caption))
+	if err != nil {
+		log.Printf("Ошибка отправки изображения карты %s: %v", card.Card.ID, err)
+		return 0
+	}
+	rememberCardPhoto(card, msg)
+	return msg.MessageID
+}
+
+// Функция rememberCardPhoto запоминает в кэше file_id, который Telegram присвоил
+// загруженному с диска изображению карты.
+func rememberCardPhoto(card DrawnCard, msg tgbotapi.Message) {
+	// Telegram присылает несколько размеров фото, последний — самый крупный
+	if !isCardImagePath(card.Card.Image) || len(msg.Photo) == 0 {
+		return
+	}
+	if err := cardImages.Remember(cardImageKey(card), msg.Photo[len(msg.Photo)-1].FileID); err != nil {
+		log.Printf("Ошибка сохранения кэша изображений: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Файл перевёрнутой карты загружается повёрнутым, а его file_id кэшируется отдельно от прямого.
+func TestCardImageMediaReversed(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255}) // Красная точка в левом верхнем углу
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "major-00.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := NewCardImageCache("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upright := DrawnCard{Card: Card{ID: "major-00", Image: path}}
+	reversed := DrawnCard{Card: upright.Card, Reversed: true}
+
+	if _, ok := cache.Media(upright).(tgbotapi.FilePath); !ok {
+		t.Fatal("прямая карта должна загружаться из файла как есть")
+	}
+	file, ok := cache.Media(reversed).(tgbotapi.FileBytes)
+	if !ok {
+		t.Fatal("перевёрнутая карта должна загружаться повёрнутой копией")
+	}
+	rotated, err := png.Decode(bytes.NewReader(file.Bytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := rotated.At(1, 2).RGBA(); r == 0 {
+		t.Error("красная точка должна оказаться в правом нижнем углу")
+	}
+
+	if err := cache.Remember(cardImageKey(upright), "upright-id"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cache.Media(reversed).(tgbotapi.FileBytes); !ok {
+		t.Error("file_id прямой карты не должен использоваться для перевёрнутой")
+	}
+	if err := cache.Remember(cardImageKey(reversed), "reversed-id"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.Media(reversed); got != tgbotapi.FileID("reversed-id") {
+		t.Errorf("Media = %v, ожидался file_id повёрнутой копии", got)
+	}
+}
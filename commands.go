@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Функция runCommand выполняет служебную подкоманду вместо запуска бота
+// и возвращает код завершения процесса.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "migrate-deck":
+		return runMigrateDeck(args[1:])
+	case "validate-deck":
+		return runValidateDeck(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Неизвестная команда %q. Доступные команды: migrate-deck, validate-deck\n", args[0])
+		return 2
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Arcana — аркан карты: старший или младший.
+type Arcana string
+
+const (
+	ArcanaMajor Arcana = "major" // Старший аркан
+	ArcanaMinor Arcana = "minor" // Младший аркан
+)
+
+// Масти младших арканов в порядке колоды.
+const (
+	SuitWands     = "wands"     // Жезлы
+	SuitCups      = "cups"      // Кубки
+	SuitSwords    = "swords"    // Мечи
+	SuitPentacles = "pentacles" // Пентакли
+)
+
+// Card — карта колоды Таро с обоими значениями: прямым и перевёрнутым.
+type Card struct {
+	ID       string `json:"id"`             // Уникальный идентификатор, например "major-00" или "cups-13"
+	Name     string `json:"name"`           // Название карты без указания положения
+	Arcana   Arcana `json:"arcana"`         // Старший или младший аркан
+	Suit     string `json:"suit,omitempty"` // Масть (только для младших арканов)
+	Rank     int    `json:"rank"`           // Номер старшего аркана (0–21) или достоинство младшего (1–14)
+	Upright  string `json:"upright"`        // Значение в прямом положении
+	Reversed string `json:"reversed"`       // Значение в перевёрнутом положении
+	// Необязательное изображение карты: путь к файлу (например, "images/major-00.jpg"),
+	// ссылка http(s) или file_id уже загруженного в Telegram фото.
+	Image string `json:"image,omitempty"`
+}
+
+// DrawnCard — вытянутая карта вместе с её положением в раскладе.
+type DrawnCard struct {
+	Card     Card `json:"card"`
+	Reversed bool `json:"reversed"` // true — карта выпала перевёрнутой
+}
+
+// Orientation возвращает положение карты словами.
+func (d DrawnCard) Orientation() string {
+	if d.Reversed {
+		return "перевёрнутое положение"
+	}
+	return "прямое положение"
+}
+
+// Title возвращает название карты с положением, например "🃏Шут (прямое положение)".
+func (d DrawnCard) Title() string {
+	return "🃏" + d.Card.Name + " (" + d.Orientation() + ")"
+}
+
+// Meaning возвращает значение карты с учётом положения.
+func (d DrawnCard) Meaning() string {
+	if d.Reversed {
+		return d.Card.Reversed
+	}
+	return d.Card.Upright
+}
+
+// majorArcanaNames — названия старших арканов по номеру.
+var majorArcanaNames = []string{
+	"Шут", "Маг", "Верховная Жрица", "Императрица", "Император", "Иерофант", "Влюбленные",
+	"Колесница", "Сила", "Отшельник", "Колесо Фортуны", "Правосудие", "Повешенный", "Смерть",
+	"Умеренность", "Дьявол", "Башня", "Звезда", "Луна", "Солнце", "Суд", "Мир",
+}
+
+// minorRankNames — названия достоинств младших арканов (индекс 0 не используется).
+var minorRankNames = []string{
+	"", "Туз", "Двойка", "Тройка", "Четверка", "Пятерка", "Шестерка", "Семерка",
+	"Восьмерка", "Девятка", "Десятка", "Паж", "Рыцарь", "Королева", "Король",
+}
+
+// suitNames — названия мастей в родительном падеже, как они пишутся в названии карты.
+var suitNames = map[string]string{
+	SuitWands:     "Жезлов",
+	SuitCups:      "Кубков",
+	SuitSwords:    "Мечей",
+	SuitPentacles: "Пентаклей",
+}
+
+// suitOrder — порядок мастей в колоде.
+var suitOrder = []string{SuitWands, SuitCups, SuitSwords, SuitPentacles}
+
+// Функция cardID строит идентификатор карты по аркану, масти и номеру.
+func cardID(arcana Arcana, suit string, rank int) string {
+	if arcana == ArcanaMajor {
+		return fmt.Sprintf("major-%02d", rank)
+	}
+	return fmt.Sprintf("%s-%02d", suit, rank)
+}
+
+// Функция canonicalCardName возвращает каноническое название карты.
+func canonicalCardName(arcana Arcana, suit string, rank int) string {
+	if arcana == ArcanaMajor {
+		return majorArcanaNames[rank]
+	}
+	return minorRankNames[rank] + " " + suitNames[suit]
+}
+
+// Функция загрузки карт из JSON-файла
+func loadTarotCards(filename string) ([]Card, error) {
+	// Читаем содержимое файла
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err // В случае ошибки возвращаем nil и ошибку
+	}
+
+	// Создаём слайс для хранения карт
+	var cards []Card
+
+	// Разбираем JSON в слайс структур Card
+	err = json.Unmarshal(file, &cards)
+	if err != nil {
+		return nil, err // Если возникла ошибка при разборе, возвращаем nil и ошибку
+	}
+
+	return cards, nil // Возвращаем загруженные карты
+}
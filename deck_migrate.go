@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TarotCard — карта в старом формате tarocards.json, где каждое положение карты
+// хранилось отдельной записью. Используется только при миграции.
+type TarotCard struct {
+	Name        string `json:"name"`        // Название карты вместе с положением
+	Description string `json:"description"` // Значение карты в этом положении
+}
+
+// Функция loadLegacyTarotCards загружает карты в старом формате.
+func loadLegacyTarotCards(filename string) ([]TarotCard, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var cards []TarotCard
+	if err := json.Unmarshal(file, &cards); err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
+
+// Функция migrateDeck объединяет записи старого формата в карты с прямым и перевёрнутым значением.
+// Возвращает карты в порядке колоды и список предупреждений о записях, которые не удалось сопоставить.
+func migrateDeck(legacy []TarotCard) ([]Card, []string) {
+	var warnings []string
+	byID := make(map[string]*Card)
+
+	for _, entry := range legacy {
+		name, reversed, ok := parseLegacyName(entry.Name)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("не удалось определить положение карты %q", entry.Name))
+			continue
+		}
+		arcana, suit, rank, ok := identifyCard(name)
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("неизвестная карта %q", entry.Name))
+			continue
+		}
+
+		id := cardID(arcana, suit, rank)
+		card, exists := byID[id]
+		if !exists {
+			card = &Card{
+				ID:     id,
+				Name:   canonicalCardName(arcana, suit, rank),
+				Arcana: arcana,
+				Suit:   suit,
+				Rank:   rank,
+			}
+			byID[id] = card
+		}
+
+		meaning := strings.TrimSpace(entry.Description)
+		target := &card.Upright
+		if reversed {
+			target = &card.Reversed
+		}
+		if *target != "" {
+			warnings = append(warnings, fmt.Sprintf("повторная запись %q", entry.Name))
+			continue
+		}
+		*target = meaning
+	}
+
+	// Собираем карты в порядке колоды: старшие арканы, затем масти по порядку.
+	var cards []Card
+	for rank := range majorArcanaNames {
+		if card, ok := byID[cardID(ArcanaMajor, "", rank)]; ok {
+			cards = append(cards, *card)
+		}
+	}
+	for _, suit := range suitOrder {
+		for rank := 1; rank < len(minorRankNames); rank++ {
+			if card, ok := byID[cardID(ArcanaMinor, suit, rank)]; ok {
+				cards = append(cards, *card)
+			}
+		}
+	}
+	return cards, warnings
+}
+
+// Функция parseLegacyName отделяет название карты от положения.
+// Поддерживает оба встречающихся формата: "🃏Шут (прямое положение)" и "🃏Маг Прямое положение".
+func parseLegacyName(raw string) (name string, reversed bool, ok bool) {
+	name = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "🃏"))
+	lower := strings.ToLower(strings.ReplaceAll(name, "ё", "е"))
+
+	var idx int
+	switch {
+	case strings.Contains(lower, "перевернутое положение"):
+		reversed = true
+		idx = strings.Index(lower, "перевернутое положение")
+	case strings.Contains(lower, "прямое положение"):
+		idx = strings.Index(lower, "прямое положение")
+	default:
+		return "", false, false
+	}
+
+	// Индекс найден в строке с заменённой "ё"; обе буквы занимают по два байта,
+	// поэтому он совпадает с индексом в исходной строке.
+	name = strings.TrimRight(strings.TrimSpace(name[:idx]), " (")
+	return strings.TrimSpace(name), reversed, true
+}
+
+// Функция identifyCard определяет аркан, масть и номер карты по её названию.
+func identifyCard(name string) (arcana Arcana, suit string, rank int, ok bool) {
+	normalized := normalizeCardName(name)
+	for i, major := range majorArcanaNames {
+		if normalizeCardName(major) == normalized {
+			return ArcanaMajor, "", i, true
+		}
+	}
+	for _, s := range suitOrder {
+		for r := 1; r < len(minorRankNames); r++ {
+			if normalizeCardName(canonicalCardName(ArcanaMinor, s, r)) == normalized {
+				return ArcanaMinor, s, r, true
+			}
+		}
+	}
+	return "", "", 0, false
+}
+
+// Функция normalizeCardName приводит название к виду для сравнения:
+// нижний регистр, "ё" → "е", одиночные пробелы.
+func normalizeCardName(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(name, "ё", "е"), "Ё", "Е"))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Функция runMigrateDeck реализует подкоманду migrate-deck: читает колоду в старом формате
+// и записывает её в новом. Входной и выходной файл могут совпадать.
+func runMigrateDeck(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Использование: migrate-deck <старый.json> <новый.json>")
+		return 2
+	}
+
+	legacy, err := loadLegacyTarotCards(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка загрузки карт:", err)
+		return 1
+	}
+
+	cards, warnings := migrateDeck(legacy)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Предупреждение:", warning)
+	}
+
+	data, err := json.MarshalIndent(cards, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка сериализации колоды:", err)
+		return 1
+	}
+	if err := writeFileAtomic(args[1], append(data, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	fmt.Printf("Перенесено %d записей в %d карт, файл %s записан.\n", len(legacy), len(cards), args[1])
+	return 0
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Deck — неизменяемый снимок колоды. После создания не меняется,
+// поэтому его можно без блокировок читать из любого числа обработчиков.
+type Deck struct {
+	cards    []Card
+	byID     map[string]int
+	loadedAt time.Time
+}
+
+// Функция newDeck создаёт снимок колоды из загруженных карт.
+func newDeck(cards []Card) *Deck {
+	deck := &Deck{
+		cards:    append([]Card(nil), cards...),
+		byID:     make(map[string]int, len(cards)),
+		loadedAt: time.Now(),
+	}
+	for i, card := range deck.cards {
+		deck.byID[card.ID] = i
+	}
+	return deck
+}
+
+// Len возвращает число карт в колоде.
+func (d *Deck) Len() int {
+	return len(d.cards)
+}
+
+// Cards возвращает копию списка карт: вызывающий код может менять её, не затрагивая колоду.
+func (d *Deck) Cards() []Card {
+	return append([]Card(nil), d.cards...)
+}
+
+// Card находит карту по ID.
+func (d *Deck) Card(id string) (Card, bool) {
+	i, ok := d.byID[id]
+	if !ok {
+		return Card{}, false
+	}
+	return d.cards[i], true
+}
+
+// LoadedAt возвращает время загрузки колоды.
+func (d *Deck) LoadedAt() time.Time {
+	return d.loadedAt
+}
+
+// DeckRepository загружает колоду из файла один раз и отдаёт её из памяти.
+// Перезагрузка атомарно подменяет снимок: обработчики, уже получившие старую колоду,
+// дорабатывают с ней, новые получают обновлённую.
+type DeckRepository struct {
+	path    string
+	current atomic.Pointer[Deck]
+
+	mu      sync.Mutex // Не даёт двум перезагрузкам идти одновременно
+	modTime time.Time  // Время изменения файла на момент последней загрузки
+}
+
+// NewDeckRepository загружает и проверяет колоду из файла path.
+// Ошибка загрузки или проверки возвращается сразу, чтобы бот не запустился с неисправной колодой.
+func NewDeckRepository(path string) (*DeckRepository, error) {
+	r := &DeckRepository{path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Deck возвращает текущий снимок колоды.
+func (r *DeckRepository) Deck() *Deck {
+	return r.current.Load()
+}
+
+// Reload перечитывает файл колоды. Если новая колода не загрузилась или не прошла проверку,
+// продолжает использоваться прежняя, а ошибка возвращается вызывающему.
+func (r *DeckRepository) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения колоды: %v", err)
+	}
+	cards, err := loadValidDeck(r.path)
+	if err != nil {
+		return err
+	}
+
+	r.current.Store(newDeck(cards))
+	r.modTime = info.ModTime()
+	return nil
+}
+
+// Watch раз в interval проверяет время изменения файла и перезагружает колоду при изменении.
+// Работает, пока не отменён ctx.
+func (r *DeckRepository) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(r.path)
+		if err != nil {
+			log.Printf("Не удалось проверить файл колоды: %v", err)
+			continue
+		}
+		r.mu.Lock()
+		changed := !info.ModTime().Equal(r.modTime)
+		r.mu.Unlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.Reload(); err != nil {
+			log.Printf("Колода изменилась, но не перезагружена: %v", err)
+			// Запоминаем время изменения, чтобы не повторять ошибку на каждом тике.
+			r.mu.Lock()
+			r.modTime = info.ModTime()
+			r.mu.Unlock()
+			continue
+		}
+		log.Printf("Колода перезагружена: %d карт", r.Deck().Len())
+	}
+}
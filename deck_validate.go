@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// Ограничения на длину значения карты: слишком короткое не несёт смысла,
+// слишком длинное не помещается в сообщение с раскладом из десяти карт.
+const (
+	minMeaningLength = 60
+	maxMeaningLength = 400
+)
+
+// DeckIssue — одна найденная в колоде проблема.
+type DeckIssue struct {
+	CardID  string // ID карты, к которой относится проблема (пусто — проблема колоды целиком)
+	Message string // Описание проблемы
+}
+
+func (i DeckIssue) String() string {
+	if i.CardID == "" {
+		return i.Message
+	}
+	return i.CardID + ": " + i.Message
+}
+
+// Функция validateDeck проверяет полноту и корректность колоды:
+// наличие всех 78 карт, отсутствие дубликатов, заполненность и длину значений, единообразие названий
+// и наличие файлов изображений.
+func validateDeck(cards []Card) []DeckIssue {
+	var issues []DeckIssue
+	seen := make(map[string]bool)
+	names := make(map[string]string)
+
+	for i, card := range cards {
+		id := card.ID
+		if id == "" {
+			id = fmt.Sprintf("#%d", i+1)
+			issues = append(issues, DeckIssue{id, "не задан id"})
+		}
+
+		// Дубликаты по id и по названию
+		if seen[card.ID] && card.ID != "" {
+			issues = append(issues, DeckIssue{id, "карта встречается в колоде несколько раз"})
+		}
+		seen[card.ID] = true
+		key := normalizeCardName(card.Name)
+		if other, ok := names[key]; ok && other != card.ID {
+			issues = append(issues, DeckIssue{id, fmt.Sprintf("название %q совпадает с картой %s", card.Name, other)})
+		}
+		names[key] = card.ID
+
+		// Аркан, масть и номер должны быть допустимыми и соответствовать id
+		switch card.Arcana {
+		case ArcanaMajor:
+			if card.Suit != "" {
+				issues = append(issues, DeckIssue{id, "у старшего аркана не должно быть масти"})
+			}
+			if card.Rank < 0 || card.Rank >= len(majorArcanaNames) {
+				issues = append(issues, DeckIssue{id, fmt.Sprintf("недопустимый номер старшего аркана %d", card.Rank)})
+				continue
+			}
+		case ArcanaMinor:
+			if _, ok := suitNames[card.Suit]; !ok {
+				issues = append(issues, DeckIssue{id, fmt.Sprintf("неизвестная масть %q", card.Suit)})
+				continue
+			}
+			if card.Rank < 1 || card.Rank >= len(minorRankNames) {
+				issues = append(issues, DeckIssue{id, fmt.Sprintf("недопустимое достоинство %d", card.Rank)})
+				continue
+			}
+		default:
+			issues = append(issues, DeckIssue{id, fmt.Sprintf("неизвестный аркан %q", card.Arcana)})
+			continue
+		}
+		if want := cardID(card.Arcana, card.Suit, card.Rank); card.ID != want {
+			issues = append(issues, DeckIssue{id, fmt.Sprintf("id не соответствует аркану, масти и номеру, ожидается %s", want)})
+		}
+
+		// Название должно совпадать с каноническим, без эмодзи и указания положения
+		if want := canonicalCardName(card.Arcana, card.Suit, card.Rank); card.Name != want {
+			issues = append(issues, DeckIssue{id, fmt.Sprintf("название %q не соответствует принятому %q", card.Name, want)})
+		}
+
+		issues = append(issues, validateMeaning(id, "прямое", card.Upright)...)
+		issues = append(issues, validateMeaning(id, "перевёрнутое", card.Reversed)...)
+
+		// Изображение необязательно, но указанный файл должен существовать
+		if isCardImagePath(card.Image) {
+			if _, err := os.Stat(card.Image); err != nil {
+				issues = append(issues, DeckIssue{id, fmt.Sprintf("файл изображения %q недоступен: %v", card.Image, err)})
+			}
+		}
+	}
+
+	// Проверяем, что в колоде есть все 78 карт
+	for rank := range majorArcanaNames {
+		if id := cardID(ArcanaMajor, "", rank); !seen[id] {
+			issues = append(issues, DeckIssue{id, fmt.Sprintf("нет карты %q", majorArcanaNames[rank])})
+		}
+	}
+	for _, suit := range suitOrder {
+		for rank := 1; rank < len(minorRankNames); rank++ {
+			if id := cardID(ArcanaMinor, suit, rank); !seen[id] {
+				issues = append(issues, DeckIssue{id, fmt.Sprintf("нет карты %q", canonicalCardName(ArcanaMinor, suit, rank))})
+			}
+		}
+	}
+
+	return issues
+}
+
+// Функция validateMeaning проверяет значение карты в одном положении.
+func validateMeaning(cardID, orientation, meaning string) []DeckIssue {
+	length := utf8.RuneCountInString(strings.TrimSpace(meaning))
+	switch {
+	case length == 0:
+		return []DeckIssue{{cardID, fmt.Sprintf("пустое значение (%s положение)", orientation)}}
+	case length < minMeaningLength:
+		return []DeckIssue{{cardID, fmt.Sprintf("слишком короткое значение (%s положение): %d символов", orientation, length)}}
+	case length > maxMeaningLength:
+		return []DeckIssue{{cardID, fmt.Sprintf("слишком длинное значение (%s положение): %d символов, максимум %d", orientation, length, maxMeaningLength)}}
+	}
+	return nil
+}
+
+// Функция loadValidDeck загружает колоду и возвращает ошибку, если она не прошла проверку.
+func loadValidDeck(filename string) ([]Card, error) {
+	cards, err := loadTarotCards(filename)
+	if err != nil {
+		return nil, err
+	}
+	if issues := validateDeck(cards); len(issues) > 0 {
+		lines := make([]string, len(issues))
+		for i, issue := range issues {
+			lines[i] = issue.String()
+		}
+		return nil, fmt.Errorf("колода %s не прошла проверку (%d проблем):\n%s", filename, len(issues), strings.Join(lines, "\n"))
+	}
+	return cards, nil
+}
+
+// Функция runValidateDeck реализует подкоманду validate-deck: печатает найденные проблемы
+// и завершается с ненулевым кодом, если колода некорректна.
+func runValidateDeck(args []string) int {
+	filename := "tarocards.json"
+	if len(args) > 0 {
+		filename = args[0]
+	}
+
+	cards, err := loadTarotCards(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка загрузки карт:", err)
+		return 1
+	}
+
+	issues := validateDeck(cards)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		fmt.Printf("Колода %s: %d карт, найдено проблем: %d\n", filename, len(cards), len(issues))
+		return 1
+	}
+	fmt.Printf("Колода %s: %d карт, проблем не найдено.\n", filename, len(cards))
+	return 0
+}
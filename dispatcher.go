@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Dispatcher обрабатывает обновления параллельно, но сохраняет порядок внутри одного чата:
+// пока обновление чата обрабатывается, следующие обновления этого чата ждут в его очереди.
+// Число одновременно работающих обработчиков ограничено размером пула.
+type Dispatcher struct {
+	mu      sync.Mutex
+	pending map[int64][]tgbotapi.Update // Очереди чатов, у которых сейчас есть активный обработчик
+	slots   chan struct{}               // Семафор, ограничивающий число параллельных обработчиков
+	handle  func(tgbotapi.Update)
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher создаёт диспетчер с пулом из workers обработчиков.
+func NewDispatcher(workers int, handle func(tgbotapi.Update)) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{
+		pending: make(map[int64][]tgbotapi.Update),
+		slots:   make(chan struct{}, workers),
+		handle:  handle,
+	}
+}
+
+// Dispatch ставит обновление в очередь его чата и не блокирует цикл получения обновлений.
+func (d *Dispatcher) Dispatch(update tgbotapi.Update) {
+	key := updateKey(update)
+
+	d.mu.Lock()
+	if queue, busy := d.pending[key]; busy {
+		d.pending[key] = append(queue, update)
+		d.mu.Unlock()
+		return
+	}
+	d.pending[key] = nil
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go d.run(key, update)
+}
+
+// Wait дожидается завершения обработки всех принятых обновлений.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// run последовательно обрабатывает обновления одного чата, пока его очередь не опустеет.
+func (d *Dispatcher) run(key int64, update tgbotapi.Update) {
+	defer d.wg.Done()
+	for {
+		d.slots <- struct{}{}
+		d.safeHandle(update)
+		<-d.slots
+
+		d.mu.Lock()
+		queue := d.pending[key]
+		if len(queue) == 0 {
+			delete(d.pending, key)
+			d.mu.Unlock()
+			return
+		}
+		update, d.pending[key] = queue[0], queue[1:]
+		d.mu.Unlock()
+	}
+}
+
+// safeHandle вызывает обработчик и не даёт панике в одном обновлении уронить весь бот.
+func (d *Dispatcher) safeHandle(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Паника при обработке обновления %d: %v\n%s", update.UpdateID, r, debug.Stack())
+		}
+	}()
+	d.handle(update)
+}
+
+// updateKey возвращает ключ, по которому сериализуется обработка: ID чата,
+// а для обновлений без чата — ID отправителя.
+func updateKey(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Draw — результат вытягивания карт вместе с зерном генератора.
+// По зерну, той же колоде и тому же числу карт расклад можно воспроизвести через Drawer.Replay.
+type Draw struct {
+	Seed  int64       `json:"seed"`
+	Cards []DrawnCard `json:"cards"`
+}
+
+// Drawer вытягивает карты из колоды. Каждый расклад получает собственный генератор,
+// созданный из нового зерна, поэтому Drawer безопасен для параллельного использования
+// и никогда не меняет колоду.
+type Drawer struct {
+	mu       sync.Mutex
+	nextSeed func() int64 // Источник зёрен; вызывается под блокировкой mu
+}
+
+// NewDrawer создаёт Drawer, который берёт зёрна из криптографического генератора.
+func NewDrawer() *Drawer {
+	return &Drawer{nextSeed: cryptoSeed}
+}
+
+// NewSeededDrawer создаёт детерминированный Drawer: при одном и том же seed
+// последовательность раскладов всегда одинакова. Нужен для тестов и отладки.
+func NewSeededDrawer(seed int64) *Drawer {
+	seeds := rand.New(rand.NewSource(seed))
+	return &Drawer{nextSeed: seeds.Int63}
+}
+
+// Draw вытягивает n различных карт из колоды со случайным положением каждой.
+func (d *Drawer) Draw(deck *Deck, n int) (Draw, error) {
+	d.mu.Lock()
+	seed := d.nextSeed()
+	d.mu.Unlock()
+	return d.Replay(deck, n, seed)
+}
+
+// Replay вытягивает карты с заданным зерном. Для той же колоды результат всегда одинаков.
+func (d *Drawer) Replay(deck *Deck, n int, seed int64) (Draw, error) {
+	if n < 0 || n > deck.Len() {
+		return Draw{}, fmt.Errorf("нельзя вытянуть %d карт из колоды в %d карт", n, deck.Len())
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	// Перемешиваем индексы, а не саму колоду, и берём первые n
+	order := rng.Perm(deck.Len())[:n]
+
+	cards := make([]DrawnCard, n)
+	for i, idx := range order {
+		// Положение каждой карты выбирается независимо
+		cards[i] = DrawnCard{Card: deck.cards[idx], Reversed: rng.Intn(2) == 1}
+	}
+	return Draw{Seed: seed, Cards: cards}, nil
+}
+
+// Функция cryptoSeed возвращает зерно из криптографического генератора.
+func cryptoSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("не удалось получить случайное зерно: %v", err))
+	}
+	return int64(binary.LittleEndian.Uint64(b[:]) &^ (1 << 63))
+}
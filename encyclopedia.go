@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// encyclopediaSearchLimit — сколько карт показывается в результатах поиска.
+const encyclopediaSearchLimit = 8
+
+// sectionTitles — названия разделов справочника.
+var sectionTitles = map[string]string{
+	string(ArcanaMajor): "Старшие арканы",
+	SuitWands:           "Жезлы",
+	SuitCups:            "Кубки",
+	SuitSwords:          "Мечи",
+	SuitPentacles:       "Пентакли",
+}
+
+// Функция cardSection возвращает раздел справочника, к которому относится карта.
+func cardSection(card Card) string {
+	if card.Arcana == ArcanaMajor {
+		return string(ArcanaMajor)
+	}
+	return card.Suit
+}
+
+// Функция sendEncyclopedia открывает справочник: подсказку с клавиатурой "Назад в меню"
+// и сообщение с inline-кнопками разделов. Возвращает ID обоих сообщений.
+func sendEncyclopedia(bot *tgbotapi.BotAPI, chatID int64) []int {
+	hintID := sendMessage(bot, chatID, "📚 Значения карт 📚\nВыберите раздел колоды или напишите название карты, например «Королева Кубков».")
+
+	text, markup := encyclopediaSectionsView()
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = markup
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки справочника: %v", err)
+	}
+	return []int{hintID, sentMsg.MessageID}
+}
+
+// Функция encyclopediaSectionsView возвращает текст и кнопки списка разделов колоды.
+func encyclopediaSectionsView() (string, tgbotapi.InlineKeyboardMarkup) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		callbackButton(sectionTitles[string(ArcanaMajor)], newCallbackData(callbackCards, "section", ArcanaMajor)),
+	))
+	var suits []tgbotapi.InlineKeyboardButton
+	for _, suit := range suitOrder {
+		suits = append(suits, callbackButton(sectionTitles[suit], newCallbackData(callbackCards, "section", suit)))
+	}
+	rows = append(rows, suits[:2], suits[2:], tgbotapi.NewInlineKeyboardRow(menuButton()))
+	return "Разделы колоды:", tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Функция encyclopediaSectionView возвращает текст и кнопки со всеми картами раздела section.
+func encyclopediaSectionView(section string) (string, tgbotapi.InlineKeyboardMarkup) {
+	var cards []Card
+	for _, card := range deckRepo.Deck().Cards() {
+		if cardSection(card) == section {
+			cards = append(cards, card)
+		}
+	}
+	rows := cardButtonRows(cards)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		callbackButton("📚 Разделы", newCallbackData(callbackCards, "sections")),
+	))
+	return fmt.Sprintf("%s — выберите карту:", sectionTitles[section]), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// Функция cardButtonRows раскладывает кнопки карт по две в ряд.
+func cardButtonRows(cards []Card) [][]tgbotapi.InlineKeyboardButton {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(cards); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, card := range cards[i:min(i+2, len(cards))] {
+			row = append(row, callbackButton(card.Name, newCallbackData(callbackCards, "card", card.ID)))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Функция encyclopediaCardView возвращает описание карты с прямым и перевёрнутым значением
+// и кнопки возврата к её разделу и к списку разделов.
+func encyclopediaCardView(card Card) (string, tgbotapi.InlineKeyboardMarkup) {
+	section := cardSection(card)
+	var place string
+	if card.Arcana == ArcanaMajor {
+		place = sectionTitles[section] + ", аркан " + strconv.Itoa(card.Rank)
+	} else {
+		place = "Младшие арканы, масть " + sectionTitles[section]
+	}
+	text := fmt.Sprintf("🃏 %s\n%s\n\n⬆️ Прямое положение:\n%s\n\n⬇️ Перевёрнутое положение:\n%s",
+		card.Name, place, card.Upright, card.Reversed)
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		callbackButton("⬅️ "+sectionTitles[section], newCallbackData(callbackCards, "section", section)),
+		callbackButton("📚 Разделы", newCallbackData(callbackCards, "sections")),
+	))
+	return text, markup
+}
+
+// Функция handleEncyclopedia обрабатывает сообщения в состоянии "encyclopedia":
+// "Назад в меню" возвращает в главное меню, любой другой текст — поиск карты по названию.
+func handleEncyclopedia(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	switch message.Text {
+	case backButtonLabel, "/start":
+		session.State = "main"
+		session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+		return
+	}
+	if message.Text == "" || utf8.RuneCountInString(message.Text) > 50 {
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Напишите название карты, например «Туз Мечей»."))
+		return
+	}
+
+	matches := searchCards(deckRepo.Deck().Cards(), message.Text)
+	var text string
+	var markup tgbotapi.InlineKeyboardMarkup
+	switch {
+	case len(matches) == 0:
+		text, markup = encyclopediaSectionsView()
+		text = fmt.Sprintf("Карта «%s» не найдена. Проверьте название или выберите раздел:", message.Text)
+	case len(matches) == 1:
+		sendEncyclopediaCardImage(bot, message.Chat.ID, session, matches[0])
+		text, markup = encyclopediaCardView(matches[0])
+	default:
+		rows := cardButtonRows(matches)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(callbackButton("📚 Разделы", newCallbackData(callbackCards, "sections"))))
+		text, markup = "Возможно, вы искали:", tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = markup
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки результатов поиска карты: %v", err)
+	}
+	session.rememberBotMessage(sentMsg.MessageID)
+}
+
+// Функция handleEncyclopediaCallback обрабатывает inline-кнопки справочника:
+// "cards:sections", "cards:section:<раздел>" и "cards:card:<ID карты>".
+func handleEncyclopediaCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string {
+	var text string
+	var markup tgbotapi.InlineKeyboardMarkup
+	switch {
+	case data.Action == "sections":
+		text, markup = encyclopediaSectionsView()
+	case data.Action == "section" && sectionTitles[data.Arg(0)] != "":
+		text, markup = encyclopediaSectionView(data.Arg(0))
+	case data.Action == "card":
+		card, ok := deckRepo.Deck().Card(data.Arg(0))
+		if !ok {
+			// Карта могла пропасть после перезагрузки колоды
+			text, markup = encyclopediaSectionsView()
+			editInlineMessage(bot, query.Message, text, &markup)
+			return "Этой карты больше нет в колоде."
+		}
+		text, markup = encyclopediaCardView(card)
+		editInlineMessage(bot, query.Message, text, &markup)
+		sendEncyclopediaCardImage(bot, query.Message.Chat.ID, session, card)
+		return ""
+	default:
+		return unknownCallback(query)
+	}
+	editInlineMessage(bot, query.Message, text, &markup)
+	return ""
+}
+
+// Функция sendEncyclopediaCardImage присылает изображение открытой в справочнике карты, если оно задано.
+// Описание карты не помещается в подпись к фото, поэтому изображение отправляется отдельным сообщением.
+func sendEncyclopediaCardImage(bot *tgbotapi.BotAPI, chatID int64, session *Session, card Card) {
+	if card.Image == "" {
+		return
+	}
+	session.rememberBotMessage(sendCardPhoto(bot, chatID, DrawnCard{Card: card}, "🃏 "+card.Name))
+}
+
+// Функция searchCards ищет карты по названию с учётом опечаток.
+// Точное совпадение и вхождение строки ценятся выше; иначе каждое слово запроса должно
+// отличаться от какого-нибудь слова названия не больше чем на одну-две буквы.
+// Слова запроса можно писать в любом порядке. Результаты упорядочены по близости, затем по колоде.
+func searchCards(cards []Card, query string) []Card {
+	q := normalizeCardName(query)
+	if q == "" {
+		return nil
+	}
+	queryWords := strings.Fields(q)
+
+	type match struct {
+		card  Card
+		score int
+		index int
+	}
+	var matches []match
+	for i, card := range cards {
+		name := normalizeCardName(card.Name)
+		var score int
+		switch {
+		case name == q:
+			score = 0
+		case utf8.RuneCountInString(q) >= 3 && strings.Contains(name, q):
+			score = 1
+		default:
+			score = wordsDistance(queryWords, strings.Fields(name))
+			if score >= 0 {
+				score += 2
+			}
+		}
+		if score >= 0 {
+			matches = append(matches, match{card, score, i})
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		if matches[a].score != matches[b].score {
+			return matches[a].score < matches[b].score
+		}
+		return matches[a].index < matches[b].index
+	})
+	// Точное совпадение однозначно: остальные варианты не показываем
+	if len(matches) > 0 && matches[0].score == 0 {
+		matches = matches[:1]
+	}
+
+	var result []Card
+	for _, m := range matches[:min(len(matches), encyclopediaSearchLimit)] {
+		result = append(result, m.card)
+	}
+	return result
+}
+
+// Функция wordsDistance сопоставляет каждое слово запроса с ближайшим словом названия
+// и возвращает сумму расстояний или -1, если какое-то слово не похоже ни на одно.
+func wordsDistance(queryWords, nameWords []string) int {
+	total := 0
+	for _, qw := range queryWords {
+		best := -1
+		for _, nw := range nameWords {
+			d := levenshtein(qw, nw)
+			// Общее начало слова тоже подходит: «кор» — «королева», «кубки» — «кубков»
+			if common := commonPrefixLen(qw, nw); common >= 3 && common >= utf8.RuneCountInString(qw)-2 {
+				d = min(d, 1)
+			}
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+		tolerance := 1
+		if utf8.RuneCountInString(qw) > 5 {
+			tolerance = 2
+		}
+		if best < 0 || best > tolerance {
+			return -1
+		}
+		total += best
+	}
+	return total
+}
+
+// Функция commonPrefixLen возвращает длину общего начала строк в символах.
+func commonPrefixLen(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return n
+}
+
+// Функция levenshtein считает расстояние Левенштейна между строками по символам.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Функция setupEncyclopedia подставляет колоду из tarocards.json, в которой у Шута есть изображение.
+func setupEncyclopedia(t *testing.T) {
+	t.Helper()
+	oldDeck, oldImages := deckRepo, cardImages
+	t.Cleanup(func() { deckRepo, cardImages = oldDeck, oldImages })
+
+	dir := t.TempDir()
+	image := filepath.Join(dir, "major-00.png")
+	if err := os.WriteFile(image, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cards, err := loadTarotCards("tarocards.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range cards {
+		if cards[i].ID == "major-00" {
+			cards[i].Image = image
+		}
+	}
+	data, _ := json.Marshal(cards)
+	deckPath := filepath.Join(dir, "deck.json")
+	if err := os.WriteFile(deckPath, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if deckRepo, err = NewDeckRepository(deckPath); err != nil {
+		t.Fatal(err)
+	}
+	cardImages, _ = NewCardImageCache("")
+}
+
+// Открытая в справочнике карта показывается с изображением, если оно задано.
+func TestEncyclopediaCardShowsImage(t *testing.T) {
+	setupEncyclopedia(t)
+	tests := []struct {
+		cardID     string
+		wantPhotos int
+	}{
+		{"major-00", 1},
+		{"major-01", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cardID, func(t *testing.T) {
+			bot, fake := newFakeBot(t)
+			var session Session
+			handleEncyclopediaCallback(bot, testCallbackQuery(1, "cards:card:"+tt.cardID), &session, CallbackData{Section: callbackCards, Action: "card", Args: []string{tt.cardID}})
+			if got := len(fake.calls("sendPhoto")); got != tt.wantPhotos {
+				t.Errorf("отправлено %d фото, ожидалось %d", got, tt.wantPhotos)
+			}
+			if got := len(fake.calls("editMessageText")); got != 1 {
+				t.Errorf("описание карты должно обновиться на месте, вызовов editMessageText: %d", got)
+			}
+		})
+	}
+}
+
+func TestSearchCards(t *testing.T) {
+	cards, err := loadTarotCards("tarocards.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  []string // Названия первых найденных карт по порядку (nil — ничего не найдено)
+		only  bool     // Найдена ровно одна карта
+	}{
+		{"точное совпадение", "Туз Мечей", []string{"Туз Мечей"}, true},
+		{"регистр и лишние пробелы", "  туз   МЕЧЕЙ ", []string{"Туз Мечей"}, true},
+		{"ё и е не различаются", "ВЛЮБЛЁННЫЕ", []string{"Влюбленные"}, true},
+		{"опечатка в одну букву", "Отшельнек", []string{"Отшельник"}, false},
+		{"две опечатки в длинном слове", "Имперотрица", []string{"Императрица"}, false},
+		{"слова в другом порядке", "мечей туз", []string{"Туз Мечей"}, false},
+		{"часть названия", "колесо", []string{"Колесо Фортуны"}, false},
+		{"нет такой карты", "Звездолёт", nil, false},
+		{"короткий запрос далеко от названий", "xyz", nil, false},
+		{"пустой запрос", "   ", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := searchCards(cards, tt.query)
+			if tt.want == nil {
+				if len(got) != 0 {
+					t.Fatalf("ожидалось, что ничего не найдётся, найдено %d: %s", len(got), got[0].Name)
+				}
+				return
+			}
+			if len(got) < len(tt.want) {
+				t.Fatalf("найдено %d карт, ожидалось не меньше %d", len(got), len(tt.want))
+			}
+			for i, name := range tt.want {
+				if got[i].Name != name {
+					t.Errorf("результат %d: %q, ожидалось %q", i, got[i].Name, name)
+				}
+			}
+			if tt.only && len(got) != 1 {
+				t.Errorf("при точном совпадении показывается одна карта, найдено %d", len(got))
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"шут", "шут", 0},
+		{"маг", "мак", 1},
+		{"жрица", "жрицы", 1},
+		{"сила", "силла", 1},
+		{"мир", "", 3},
+		{"колесо", "колесница", 4},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, ожидалось %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
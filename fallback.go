@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TemplateInterpreter детерминированно составляет толкование из значений карт,
+// позиций расклада и темы вопроса, без обращения к модели.
+// Одинаковый расклад всегда даёт одинаковый текст.
+type TemplateInterpreter struct{}
+
+// suitThemes — о чём говорит преобладание масти в раскладе.
+var suitThemes = map[string]string{
+	SuitWands:     "энергия, действия и стремления",
+	SuitCups:      "чувства, отношения и интуиция",
+	SuitSwords:    "мысли, решения и напряжение",
+	SuitPentacles: "деньги, работа и материальные дела",
+}
+
+// errFollowUpOffline — уточняющие вопросы без модели не поддерживаются: шаблон может лишь пересказать расклад.
+var errFollowUpOffline = errors.New("офлайн-толкователь не отвечает на уточняющие вопросы")
+
+func (TemplateInterpreter) Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error) {
+	if len(req.History) > 0 {
+		return Interpretation{}, errFollowUpOffline
+	}
+
+	var b strings.Builder
+
+	// Вступление по теме расклада из spreads.json
+	intro := req.Spread.Intro
+	if intro == "" {
+		intro = "Карты откликнулись на ваш вопрос."
+	}
+	b.WriteString(intro)
+	if req.Question != "" {
+		fmt.Fprintf(&b, " Вопрос: «%s».", req.Question)
+	}
+	b.WriteString("\n\n")
+
+	// Разбор по позициям
+	for i, card := range req.Cards {
+		if i < len(req.Spread.Positions) {
+			position := req.Spread.Positions[i]
+			fmt.Fprintf(&b, "%s (%s): ", position.Name, position.Description)
+		}
+		fmt.Fprintf(&b, "карта «%s» %s. %s\n\n", card.Card.Name, orientationPrepositional(card), card.Meaning())
+	}
+
+	// Общий вывод и совет
+	b.WriteString(spreadSummary(req.Spread, req.Cards))
+	return Interpretation{Text: strings.TrimSpace(b.String()), Model: offlineModel}, nil
+}
+
+// Функция orientationPrepositional возвращает положение карты в форме "в прямом положении".
+func orientationPrepositional(card DrawnCard) string {
+	if card.Reversed {
+		return "в перевёрнутом положении"
+	}
+	return "в прямом положении"
+}
+
+// Функция spreadSummary подводит итог расклада по соотношению прямых и перевёрнутых карт,
+// числу старших арканов и преобладающей масти.
+func spreadSummary(spread Spread, cards []DrawnCard) string {
+	if len(cards) == 0 {
+		return ""
+	}
+
+	reversed, major := 0, 0
+	suits := make(map[string]int)
+	for _, card := range cards {
+		if card.Reversed {
+			reversed++
+		}
+		if card.Card.Arcana == ArcanaMajor {
+			major++
+		} else {
+			suits[card.Card.Suit]++
+		}
+	}
+
+	var parts []string
+
+	// Для расклада «да или нет» ответ определяет положение первой карты
+	if spread.YesNo {
+		if cards[0].Reversed {
+			parts = append(parts, "Скорее нет: карта легла перевёрнутой, обстоятельства пока не на вашей стороне.")
+		} else {
+			parts = append(parts, "Скорее да: карта легла прямо, обстоятельства благоприятствуют вам.")
+		}
+	} else {
+		switch {
+		case reversed == 0:
+			parts = append(parts, "Все карты легли прямо — энергия расклада благоприятна, ситуация развивается естественно.")
+		case reversed*2 < len(cards):
+			parts = append(parts, "Большинство карт легли прямо: общий настрой расклада благоприятный, хотя есть моменты, требующие внимания.")
+		case reversed == len(cards):
+			parts = append(parts, "Все карты перевёрнуты — сейчас время остановиться, переосмыслить происходящее и не торопить события.")
+		default:
+			parts = append(parts, "Много перевёрнутых карт: на пути есть препятствия и внутренние сомнения, с которыми стоит поработать.")
+		}
+	}
+
+	if major*2 > len(cards) {
+		parts = append(parts, "Старшие арканы преобладают — в ситуации действуют значимые силы, и происходящее важно для вашего пути.")
+	}
+
+	// Масть, которая встречается чаще других и минимум дважды
+	dominant, count := "", 1
+	for _, suit := range suitOrder {
+		if suits[suit] > count {
+			dominant, count = suit, suits[suit]
+		}
+	}
+	if dominant != "" {
+		parts = append(parts, fmt.Sprintf("Чаще всего выпадала масть %s: в центре внимания — %s.", suitNames[dominant], suitThemes[dominant]))
+	}
+
+	// Совет по последней карте расклада
+	last := cards[len(cards)-1]
+	if last.Reversed {
+		parts = append(parts, fmt.Sprintf("Совет: карта «%s» в итоге расклада просит не спешить и внимательно отнестись к её предостережению.", last.Card.Name))
+	} else {
+		parts = append(parts, fmt.Sprintf("Совет: опирайтесь на силу карты «%s» — она указывает, куда двигаться дальше.", last.Card.Name))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// FallbackInterpreter обращается к основному толкователю, а если тот недоступен
+// или не начал отвечать за slowAfter, отдаёт толкование запасного. Такое толкование
+// помечается флагом Fallback.
+type FallbackInterpreter struct {
+	Primary   Interpreter
+	Fallback  Interpreter
+	SlowAfter time.Duration // Сколько ждать начала ответа основного толкователя
+}
+
+func (f *FallbackInterpreter) Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error) {
+	primaryCtx, _, cancel := f.primaryContext(ctx)
+	defer cancel()
+
+	result, err := f.Primary.Interpret(primaryCtx, req)
+	if err == nil {
+		return result, nil
+	}
+	return f.fallback(ctx, req, err)
+}
+
+func (f *FallbackInterpreter) InterpretStream(ctx context.Context, req InterpretRequest, onDelta func(string)) (Interpretation, error) {
+	primaryCtx, started, cancel := f.primaryContext(ctx)
+	defer cancel()
+
+	// Начавшийся ответ не прерываем: пользователь уже видит, что толкование идёт.
+	// Видимый фрагмент отмечает начало ответа у толкователей, которые работают не по HTTP.
+	result, err := interpretStream(primaryCtx, f.Primary, req, func(delta string) {
+		started.Store(true)
+		onDelta(delta)
+	})
+	if err == nil {
+		return result, nil
+	}
+	return f.fallback(ctx, req, err)
+}
+
+// primaryContext возвращает контекст для основного толкователя, который отменяется,
+// если за SlowAfter модель не начала отвечать. SlowAfter ограничивает только ожидание
+// начала ответа: как только от модели пришёл первый байт, ответ дочитывается до общего
+// дедлайна ctx. Первый байт считается и в потоке, где модель пока только рассуждает
+// внутри <think>: видимого текста ещё нет, но модель работает.
+func (f *FallbackInterpreter) primaryContext(ctx context.Context) (context.Context, *atomic.Bool, context.CancelFunc) {
+	primaryCtx, cancel := context.WithCancelCause(ctx)
+
+	// Причина ErrModelSlow отличает эту отмену от отмены вызывающим: LLMClient засчитает её как неудачу модели
+	started := new(atomic.Bool)
+	timer := time.AfterFunc(f.SlowAfter, func() {
+		if !started.Load() {
+			cancel(ErrModelSlow)
+		}
+	})
+	primaryCtx = httptrace.WithClientTrace(primaryCtx, &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { started.Store(true) },
+	})
+	return primaryCtx, started, func() {
+		timer.Stop()
+		cancel(nil)
+	}
+}
+
+// fallback логирует ошибку основного толкователя и возвращает толкование запасного.
+func (f *FallbackInterpreter) fallback(ctx context.Context, req InterpretRequest, cause error) (Interpretation, error) {
+	log.Printf("Основной толкователь не ответил (%v), используется запасное толкование", cause)
+	result, err := f.Fallback.Interpret(ctx, req)
+	if err != nil {
+		return Interpretation{}, fmt.Errorf("основной толкователь: %v; запасной: %v", cause, err)
+	}
+	result.Fallback = true
+	return result, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpInterpreter — основной толкователь для тестов: забирает текст толкования с тестового сервера.
+type httpInterpreter struct {
+	client *LLMClient
+	url    string
+}
+
+func (h httpInterpreter) Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error) {
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := h.client.PostJSON(ctx, h.url, "", map[string]string{}, &out); err != nil {
+		return Interpretation{}, err
+	}
+	return Interpretation{Text: out.Text, Model: "test"}, nil
+}
+
+func TestFallbackInterpreterSlowAfterLimitsOnlyFirstByte(t *testing.T) {
+	tests := []struct {
+		name         string
+		headerDelay  time.Duration // Через сколько модель начинает отвечать
+		bodyDelay    time.Duration // Через сколько после начала ответа приходит тело
+		wantFallback bool
+	}{
+		{"долгий ответ после быстрого начала", 0, 100 * time.Millisecond, false},
+		{"модель не начала отвечать", 100 * time.Millisecond, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.headerDelay):
+				case <-r.Context().Done():
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				select {
+				case <-time.After(tt.bodyDelay):
+				case <-r.Context().Done():
+					return
+				}
+				w.Write([]byte(`{"text":"ответ модели"}`))
+			}))
+			defer srv.Close()
+
+			f := &FallbackInterpreter{
+				Primary:   httpInterpreter{client: &LLMClient{http: srv.Client(), breaker: NewCircuitBreaker(0, 0)}, url: srv.URL},
+				Fallback:  TemplateInterpreter{},
+				SlowAfter: 40 * time.Millisecond,
+			}
+			result, err := f.Interpret(context.Background(), InterpretRequest{Cards: []DrawnCard{{Card: Card{Name: "Шут"}}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Fallback != tt.wantFallback {
+				t.Fatalf("Fallback = %v, ожидалось %v (текст %q)", result.Fallback, tt.wantFallback, result.Text)
+			}
+		})
+	}
+}
+
+// Модель, которая долго рассуждает внутри <think>, уже отвечает: SlowAfter не должен её прерывать,
+// хотя видимый текст появляется только после него.
+func TestFallbackInterpreterStreamSlowThinking(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher := w.(http.Flusher)
+		chunks := []string{"<thi", "nk>Карта Шут", " говорит о", " начале</think>", "Ответ модели"}
+		for i, chunk := range chunks {
+			if i > 0 {
+				select {
+				case <-time.After(30 * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			fmt.Fprintf(w, "data: {\"choices\":[{\"text\":%q}]}\n\n", chunk)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	f := &FallbackInterpreter{
+		Primary:   &OllamaInterpreter{URL: srv.URL, Model: "test", Client: &LLMClient{http: srv.Client(), breaker: NewCircuitBreaker(0, 0)}},
+		Fallback:  TemplateInterpreter{},
+		SlowAfter: 40 * time.Millisecond,
+	}
+	var deltas strings.Builder
+	result, err := f.InterpretStream(context.Background(), InterpretRequest{Cards: []DrawnCard{{Card: Card{Name: "Шут"}}}}, func(delta string) {
+		deltas.WriteString(delta)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fallback || result.Text != "Ответ модели" {
+		t.Fatalf("Fallback = %v, текст %q: ожидался ответ модели", result.Fallback, result.Text)
+	}
+	if deltas.String() != "Ответ модели" {
+		t.Errorf("показан текст %q, рассуждения должны быть скрыты", deltas.String())
+	}
+}
+
+// Вступление и ответ «да или нет» берутся из описания расклада, а не из его ID:
+// новый расклад из spreads.json получает их без изменений в коде.
+func TestTemplateInterpreterUsesSpreadSettings(t *testing.T) {
+	spread := Spread{
+		ID:        "my_yes_no",
+		Intro:     "Одна карта ответит на ваш вопрос.",
+		YesNo:     true,
+		Positions: []SpreadPosition{{Name: "Ответ", Description: "да или нет"}},
+	}
+	result, err := TemplateInterpreter{}.Interpret(context.Background(), InterpretRequest{
+		Spread: spread,
+		Cards:  []DrawnCard{{Card: Card{Name: "Шут"}, Reversed: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.Text, spread.Intro) {
+		t.Errorf("толкование начинается не со вступления расклада: %q", result.Text)
+	}
+	if !strings.Contains(result.Text, "Скорее нет") {
+		t.Errorf("для перевёрнутой карты ожидался ответ «Скорее нет»: %q", result.Text)
+	}
+
+	spread.Intro, spread.YesNo = "", false
+	result, _ = TemplateInterpreter{}.Interpret(context.Background(), InterpretRequest{Spread: spread, Cards: []DrawnCard{{Card: Card{Name: "Шут"}}}})
+	if !strings.HasPrefix(result.Text, "Карты откликнулись на ваш вопрос.") || strings.Contains(result.Text, "Скорее") {
+		t.Errorf("расклад без настроек должен получить общее вступление и вывод: %q", result.Text)
+	}
+}
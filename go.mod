@@ -4,4 +4,9 @@ go 1.21.5
 
 require github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 
-require github.com/joho/godotenv v1.5.1 // indirect
+require github.com/joho/godotenv v1.5.1
+
+require (
+	golang.org/x/image v0.15.0
+	golang.org/x/text v0.14.0 // indirect
+)
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Глобальная инструкция, загружается из instruction.json при запуске.
+var guide *Guide
+
+// GuidePage — одна страница инструкции.
+type GuidePage struct {
+	Title string `json:"title"`
+	Text  string `json:"text"` // Шаблон text/template, данные — guideData
+}
+
+// Guide — многостраничная инструкция, которую пользователь листает inline-кнопками.
+type Guide struct {
+	Pages []GuidePage `json:"pages"`
+}
+
+// guideData — данные, которые подставляются в страницы инструкции,
+// чтобы описание раскладов и оплаты не расходилось с настройками бота.
+type guideData struct {
+	Spreads     []Spread // Расклады из меню вопросов
+	Default     Spread   // Расклад для вопросов, введённых вручную
+	FreeDaily   int      // Бесплатных раскладов в день
+	FollowUps   int      // Уточняющих вопросов к раскладу
+	MaxQuestion int      // Наибольшая длина вопроса в символах
+}
+
+// Функция loadGuide загружает инструкцию из JSON-файла и подставляет в страницы данные data.
+func loadGuide(filename string, data guideData) (*Guide, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var g Guide
+	if err := json.Unmarshal(file, &g); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %v", filename, err)
+	}
+	if len(g.Pages) == 0 {
+		return nil, fmt.Errorf("в инструкции нет страниц")
+	}
+
+	funcs := template.FuncMap{
+		"cards": cardsWord,
+		"positions": func(s Spread) string {
+			var names []string
+			for _, position := range s.Positions {
+				names = append(names, position.Name)
+			}
+			return strings.Join(names, ", ")
+		},
+	}
+	for i := range g.Pages {
+		page := &g.Pages[i]
+		tmpl, err := template.New(strconv.Itoa(i + 1)).Funcs(funcs).Option("missingkey=error").Parse(page.Text)
+		if err != nil {
+			return nil, fmt.Errorf("страница %d: %v", i+1, err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("страница %d: %v", i+1, err)
+		}
+		page.Text = strings.TrimSpace(b.String())
+		switch {
+		case page.Title == "" || page.Text == "":
+			return nil, fmt.Errorf("страница %d: не заданы заголовок или текст", i+1)
+		case utf16Len(page.Title+page.Text) > telegramMessageLimit-32:
+			// Оставляем запас на номер страницы, который добавляет guidePageView
+			return nil, fmt.Errorf("страница %d слишком длинная", i+1)
+		}
+	}
+	return &g, nil
+}
+
+// Функция cardsWord согласует слово «карта» с числом n.
+func cardsWord(n int) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "карта"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "карты"
+	default:
+		return "карт"
+	}
+}
+
+// Функция sendInstruction открывает инструкцию: подсказку с клавиатурой "Назад в меню"
+// и первую страницу с inline-кнопками "Далее/Назад". Возвращает ID обоих сообщений.
+func sendInstruction(bot *tgbotapi.BotAPI, chatID int64) []int {
+	hintID := sendMessage(bot, chatID, "📑 Инструкция 📑")
+
+	text, markup := guidePageView(0)
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки инструкции: %v", err)
+	}
+	return []int{hintID, sentMsg.MessageID}
+}
+
+// Функция guidePageView возвращает текст и кнопки страницы page (с нуля) инструкции.
+func guidePageView(page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	page = max(0, min(page, len(guide.Pages)-1))
+	p := guide.Pages[page]
+	text := fmt.Sprintf("%s (%d/%d)\n\n%s", p.Title, page+1, len(guide.Pages), p.Text)
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, callbackButton("⬅️ Назад", newCallbackData(callbackGuide, "page", page-1)))
+	}
+	if page < len(guide.Pages)-1 {
+		nav = append(nav, callbackButton("Далее ➡️", newCallbackData(callbackGuide, "page", page+1)))
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+	// С последней страницы можно сразу перейти к вопросу
+	if page == len(guide.Pages)-1 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton("🔮 Задать вопрос", newCallbackData(callbackMenu, menuAsk)),
+			menuButton(),
+		))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return text, &markup
+}
+
+// Функция handleGuideCallback обрабатывает inline-кнопки инструкции: "guide:page:<номер страницы>".
+func handleGuideCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string {
+	page, err := data.Int(0)
+	if data.Action != "page" || err != nil {
+		return unknownCallback(query)
+	}
+	text, markup := guidePageView(page)
+	editInlineMessage(bot, query.Message, text, markup)
+	return ""
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HistoryCard — карта сохранённого расклада.
+// Позиция и название хранятся текстом, чтобы запись не зависела от последующих правок колоды и раскладов.
+type HistoryCard struct {
+	Position string `json:"position"`
+	CardID   string `json:"card_id"`
+	Name     string `json:"name"`
+	Reversed bool   `json:"reversed"`
+}
+
+// Title возвращает название карты с положением, как при раскладе.
+func (c HistoryCard) Title() string {
+	if c.Reversed {
+		return "🃏" + c.Name + " (перевёрнутое положение)"
+	}
+	return "🃏" + c.Name + " (прямое положение)"
+}
+
+// HistoryEntry — один сделанный расклад в истории пользователя.
+type HistoryEntry struct {
+	ID             int           `json:"id"` // Порядковый номер расклада у пользователя, начиная с 1
+	Time           time.Time     `json:"time"`
+	Question       string        `json:"question"`
+	SpreadID       string        `json:"spread_id"`
+	SpreadTitle    string        `json:"spread_title"`
+	Seed           int64         `json:"seed"` // Код расклада
+	Cards          []HistoryCard `json:"cards"`
+	Interpretation string        `json:"interpretation"`
+	Model          string        `json:"model"` // Модель, которая дала толкование ("offline" — шаблонное толкование)
+}
+
+// Функция newHistoryEntry собирает запись истории по раскладу и полученному толкованию.
+func newHistoryEntry(spread Spread, question string, draw Draw, interpretation Interpretation) HistoryEntry {
+	entry := HistoryEntry{
+		Time:           time.Now(),
+		Question:       question,
+		SpreadID:       spread.ID,
+		SpreadTitle:    spread.Title,
+		Seed:           draw.Seed,
+		Interpretation: interpretation.Text,
+		Model:          interpretation.Model,
+	}
+	for i, card := range draw.Cards {
+		hc := HistoryCard{CardID: card.Card.ID, Name: card.Card.Name, Reversed: card.Reversed}
+		if i < len(spread.Positions) {
+			hc.Position = spread.Positions[i].Name
+		}
+		entry.Cards = append(entry.Cards, hc)
+	}
+	return entry
+}
+
+// HistoryStore — хранилище истории раскладов, ключ — ID пользователя Telegram.
+type HistoryStore interface {
+	// Add добавляет расклад в историю пользователя и возвращает запись с присвоенным номером.
+	Add(userID int64, entry HistoryEntry) (HistoryEntry, error)
+	// List возвращает расклады пользователя, начиная с самого нового.
+	List(userID int64) []HistoryEntry
+	// Get возвращает расклад пользователя по номеру.
+	Get(userID int64, id int) (HistoryEntry, bool)
+}
+
+// MemoryHistoryStore хранит историю раскладов только в памяти процесса.
+// У каждого пользователя хранится не больше limit последних раскладов.
+type MemoryHistoryStore struct {
+	mu      sync.RWMutex
+	limit   int
+	entries map[int64][]HistoryEntry // Расклады пользователя в порядке добавления
+}
+
+// NewMemoryHistoryStore создаёт пустое хранилище истории в памяти.
+func NewMemoryHistoryStore(limit int) *MemoryHistoryStore {
+	return &MemoryHistoryStore{limit: limit, entries: make(map[int64][]HistoryEntry)}
+}
+
+func (s *MemoryHistoryStore) Add(userID int64, entry HistoryEntry) (HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(userID, entry), nil
+}
+
+// add добавляет запись и отбрасывает самые старые сверх лимита. Вызывается под блокировкой.
+func (s *MemoryHistoryStore) add(userID int64, entry HistoryEntry) HistoryEntry {
+	list := s.entries[userID]
+	entry.ID = 1
+	if len(list) > 0 {
+		entry.ID = list[len(list)-1].ID + 1
+	}
+	list = append(list, entry)
+	if s.limit > 0 && len(list) > s.limit {
+		list = append([]HistoryEntry(nil), list[len(list)-s.limit:]...)
+	}
+	s.entries[userID] = list
+	return entry
+}
+
+func (s *MemoryHistoryStore) List(userID int64) []HistoryEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	list := s.entries[userID]
+	result := make([]HistoryEntry, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		result = append(result, list[i])
+	}
+	return result
+}
+
+func (s *MemoryHistoryStore) Get(userID int64, id int) (HistoryEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.entries[userID] {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// FileHistoryStore хранит историю в памяти и сбрасывает её в JSON-файл после каждого расклада.
+type FileHistoryStore struct {
+	*MemoryHistoryStore
+	path string
+}
+
+// NewFileHistoryStore открывает хранилище истории в файле path.
+func NewFileHistoryStore(path string, limit int) (*FileHistoryStore, error) {
+	s := &FileHistoryStore{MemoryHistoryStore: NewMemoryHistoryStore(limit), path: path}
+
+	if err := loadJSONFile(path, &s.entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileHistoryStore) Add(userID int64, entry HistoryEntry) (HistoryEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry = s.add(userID, entry)
+
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return entry, fmt.Errorf("ошибка сериализации истории: %v", err)
+	}
+	return entry, writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// historyPageSize — сколько раскладов показывается на одной странице /history.
+const historyPageSize = 5
+
+// Глобальное хранилище истории раскладов (ключ — ID пользователя).
+// Инициализируется в main в зависимости от переменной HISTORY_STORE.
+var history HistoryStore
+
+// Функция recordHistory сохраняет сделанный расклад в историю отправителя сообщения.
+// Ошибка записи только логируется: пользователь уже получил толкование.
+func recordHistory(message *tgbotapi.Message, entry HistoryEntry) {
+	if message.From == nil {
+		return
+	}
+	if _, err := history.Add(message.From.ID, entry); err != nil {
+		log.Printf("Ошибка сохранения истории пользователя %d: %v", message.From.ID, err)
+	}
+}
+
+// Функция sendHistory отправляет первую страницу истории раскладов пользователя.
+func sendHistory(bot *tgbotapi.BotAPI, chatID, userID int64) int {
+	text, markup := historyPage(userID, 0)
+	msg := tgbotapi.NewMessage(chatID, text)
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки истории: %v", err)
+	}
+	return sentMsg.MessageID
+}
+
+// Функция historyPage возвращает текст и кнопки страницы page (с нуля) истории пользователя.
+// Каждый расклад на странице — кнопка, открывающая его; внизу — переход между страницами.
+func historyPage(userID int64, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	entries := history.List(userID)
+	if len(entries) == 0 {
+		return "📜 История пуста: вы ещё не сделали ни одного расклада.", nil
+	}
+
+	pages := (len(entries) + historyPageSize - 1) / historyPageSize
+	page = max(0, min(page, pages-1))
+	start := page * historyPageSize
+	end := min(start+historyPageSize, len(entries))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, entry := range entries[start:end] {
+		label := entry.Time.Format("02.01 15:04") + " · " + entry.SpreadTitle
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			callbackButton(label, newCallbackData(callbackHistory, "open", entry.ID, page)),
+		))
+	}
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		nav = append(nav, callbackButton("◀️ Новее", newCallbackData(callbackHistory, "page", page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, callbackButton("Старше ▶️", newCallbackData(callbackHistory, "page", page+1)))
+	}
+	if len(nav) > 0 {
+		rows = append(rows, nav)
+	}
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	text := fmt.Sprintf("📜 История раскладов (страница %d из %d).\nВыберите расклад, чтобы открыть его:", page+1, pages)
+	return text, &markup
+}
+
+// Функция historyEntryText собирает текст сохранённого расклада.
+// Слишком длинное толкование обрезается, чтобы расклад поместился в одно сообщение.
+func historyEntryText(entry HistoryEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔮 %s — %s\n", entry.SpreadTitle, entry.Time.Format("02.01.2006 15:04"))
+	if entry.Question != "" {
+		fmt.Fprintf(&b, "Вопрос: %s\n", entry.Question)
+	}
+	b.WriteString("\n")
+	for _, card := range entry.Cards {
+		if card.Position != "" {
+			b.WriteString(card.Position + ": ")
+		}
+		b.WriteString(card.Title() + "\n")
+	}
+	fmt.Fprintf(&b, "\nКод расклада: %d (/replay %d)\n", entry.Seed, entry.Seed)
+	if entry.Model != "" {
+		fmt.Fprintf(&b, "Толкователь: %s\n", entry.Model)
+	}
+
+	text := b.String() + "\n" + entry.Interpretation
+	if utf16Len(text) > telegramMessageLimit {
+		text = truncateUTF16(text, telegramMessageLimit-1) + "…"
+	}
+	return text
+}
+
+// Функция handleHistoryCallback обрабатывает inline-кнопки истории раскладов:
+// "history:page:<страница>", "history:open:<номер>:<страница>" и "history:image:<номер>".
+func handleHistoryCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string {
+	var text string
+	var markup *tgbotapi.InlineKeyboardMarkup
+	switch data.Action {
+	// Переход на другую страницу списка раскладов.
+	case "page":
+		page, err := data.Int(0)
+		if err != nil {
+			return unknownCallback(query)
+		}
+		text, markup = historyPage(query.From.ID, page)
+	// Открытие сохранённого расклада с кнопкой возврата на страницу списка.
+	case "open":
+		id, err := data.Int(0)
+		if err != nil {
+			return unknownCallback(query)
+		}
+		page, _ := data.Int(1)
+		entry, ok := history.Get(query.From.ID, id)
+		if !ok {
+			text, markup = historyPage(query.From.ID, page)
+			editInlineMessage(bot, query.Message, text, markup)
+			return "Этот расклад уже удалён из истории."
+		}
+		back := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			callbackButton("🖼 Картинка", newCallbackData(callbackHistory, "image", entry.ID)),
+			callbackButton("⬅️ К списку", newCallbackData(callbackHistory, "page", page)),
+		))
+		text, markup = historyEntryText(entry), &back
+	// Картинка сохранённого расклада присылается новым сообщением, список остаётся на месте.
+	case "image":
+		id, err := data.Int(0)
+		if err != nil {
+			return unknownCallback(query)
+		}
+		entry, ok := history.Get(query.From.ID, id)
+		if !ok {
+			return "Этот расклад уже удалён из истории."
+		}
+		session.rememberBotMessage(sendSpreadImage(bot, query.Message.Chat.ID, entry.SpreadTitle, entry.Question, historyImageCards(entry)))
+		return ""
+	default:
+		return unknownCallback(query)
+	}
+
+	// Редактируем сообщение со списком на месте, а не присылаем новое.
+	editInlineMessage(bot, query.Message, text, markup)
+	return ""
+}
+
+// Функция historyImageCards восстанавливает карты сохранённого расклада для картинки.
+// Значения карт берутся из текущей колоды; если карты в ней уже нет, рисуется только название.
+func historyImageCards(entry HistoryEntry) []SpreadImageCard {
+	deck := deckRepo.Deck()
+	result := make([]SpreadImageCard, len(entry.Cards))
+	for i, hc := range entry.Cards {
+		card, ok := deck.Card(hc.CardID)
+		if !ok {
+			card = Card{ID: hc.CardID, Name: hc.Name}
+		}
+		result[i] = SpreadImageCard{Position: hc.Position, Card: DrawnCard{Card: card, Reversed: hc.Reversed}}
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InterpretRequest — всё, что нужно толкователю для ответа на вопрос.
+type InterpretRequest struct {
+	Question string        // Вопрос пользователя
+	Spread   Spread        // Расклад, по которому вытянуты карты
+	Cards    []DrawnCard   // Вытянутые карты в порядке позиций расклада
+	System   string        // Системный запрос с образом толкователя
+	History  []ChatMessage // Предыдущие реплики по этому раскладу (для уточняющих вопросов)
+	Prompt   string        // Текущий запрос пользователя для языковой модели
+}
+
+// Messages собирает запрос в формате chat completions: системный запрос,
+// предыдущие реплики и текущий запрос пользователя.
+func (r InterpretRequest) Messages() []ChatMessage {
+	var messages []ChatMessage
+	if r.System != "" {
+		messages = append(messages, ChatMessage{Role: "system", Content: r.System})
+	}
+	messages = append(messages, r.History...)
+	return append(messages, ChatMessage{Role: "user", Content: r.Prompt})
+}
+
+// FlatPrompt собирает запрос одной строкой для эндпоинта completions,
+// который не понимает ролей: реплики помечаются и идут друг за другом.
+func (r InterpretRequest) FlatPrompt() string {
+	if r.System == "" && len(r.History) == 0 {
+		return r.Prompt
+	}
+	var b strings.Builder
+	for _, message := range r.Messages() {
+		switch message.Role {
+		case "system":
+			b.WriteString(message.Content)
+		case "assistant":
+			b.WriteString("Таролог: " + message.Content)
+		default:
+			b.WriteString("Пользователь: " + message.Content)
+		}
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Таролог:")
+	return b.String()
+}
+
+// Interpretation — толкование расклада.
+type Interpretation struct {
+	Text     string // Текст толкования для пользователя
+	Model    string // Кто толковал: имя модели или "offline"
+	Fallback bool   // Толкование составлено запасным толкователем, потому что модель недоступна
+}
+
+// offlineModel — имя толкователя по шаблонам в Interpretation.Model.
+const offlineModel = "offline"
+
+// Offline сообщает, что толкование составлено по шаблону без модели: запасным толкователем
+// или при LLM_PROVIDER=canned. За такой расклад не берётся плата, и уточняющие вопросы к нему не задаются.
+func (i Interpretation) Offline() bool {
+	return i.Fallback || i.Model == offlineModel
+}
+
+// Interpreter толкует расклад. Реализации: языковая модель через Ollama,
+// OpenAI-совместимый API и офлайн-толкователь по шаблонам (TemplateInterpreter).
+type Interpreter interface {
+	Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error)
+}
+
+// Функция newInterpreter создаёт толкователя согласно настройкам:
+// LLM_PROVIDER — ollama (по умолчанию), openai или canned; LLM_URL, LLM_MODEL, LLM_API_KEY — параметры модели;
+// LLM_API — chat (по умолчанию, /v1/chat/completions) или completions (устаревший /v1/completions, только для ollama).
+// Если LLM_FALLBACK не выключен, при недоступности модели используется офлайн-толкование
+// (модель считается медленной, если не начала отвечать за LLM_FALLBACK_AFTER).
+func newInterpreter() (Interpreter, error) {
+	provider := envOrDefault("LLM_PROVIDER", "ollama")
+	if provider == "canned" {
+		return TemplateInterpreter{}, nil
+	}
+
+	primary, err := newModelInterpreter(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	useFallback, err := strconv.ParseBool(envOrDefault("LLM_FALLBACK", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("некорректное значение LLM_FALLBACK: %v", err)
+	}
+	if !useFallback {
+		return primary, nil
+	}
+	slowAfter, err := envDuration("LLM_FALLBACK_AFTER", 90*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &FallbackInterpreter{Primary: primary, Fallback: TemplateInterpreter{}, SlowAfter: slowAfter}, nil
+}
+
+// Функция newModelInterpreter создаёт толкователя, обращающегося к языковой модели.
+func newModelInterpreter(provider string) (Interpreter, error) {
+	client, err := newLLMClient()
+	if err != nil {
+		return nil, err
+	}
+	api := envOrDefault("LLM_API", "chat")
+	if api != "chat" && api != "completions" {
+		return nil, fmt.Errorf("неизвестный LLM_API: %s", api)
+	}
+	switch provider {
+	case "ollama":
+		model := envOrDefault("LLM_MODEL", "deepseek-r1:32b")
+		if api == "completions" {
+			return &OllamaInterpreter{
+				URL:    envOrDefault("LLM_URL", "http://localhost:11434/v1/completions"),
+				Model:  model,
+				Client: client,
+			}, nil
+		}
+		// Ollama поддерживает OpenAI-совместимый chat completions, ключ не нужен
+		return &OpenAIChatInterpreter{
+			URL:    envOrDefault("LLM_URL", "http://localhost:11434/v1/chat/completions"),
+			Model:  model,
+			Client: client,
+		}, nil
+	case "openai":
+		if api != "chat" {
+			return nil, fmt.Errorf("для LLM_PROVIDER=openai поддерживается только LLM_API=chat")
+		}
+		apiKey := envOrDefault("LLM_API_KEY", "")
+		if apiKey == "" {
+			return nil, fmt.Errorf("для LLM_PROVIDER=openai нужна переменная LLM_API_KEY")
+		}
+		return &OpenAIChatInterpreter{
+			URL:    envOrDefault("LLM_URL", "https://api.openai.com/v1/chat/completions"),
+			Model:  envOrDefault("LLM_MODEL", "gpt-4o-mini"),
+			APIKey: apiKey,
+			Client: client,
+		}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный LLM_PROVIDER: %s", provider)
+	}
+}
+
+// thinkBlock — рассуждения модели в тегах <think>, которые не нужно показывать пользователю.
+var thinkBlock = regexp.MustCompile(`(?s)<think>.*?</think>`)
+
+// Функция stripThinking убирает <think> ... </think> (если есть) и лишние пробелы по краям.
+func stripThinking(text string) string {
+	return strings.TrimSpace(thinkBlock.ReplaceAllString(text, ""))
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Откуда оплачен расклад.
+const (
+	ReadingFree         = "free"         // Бесплатный расклад из дневной нормы
+	ReadingSubscription = "subscription" // Расклад по действующей подписке
+	ReadingPaid         = "paid"         // Расклад из купленного пакета
+)
+
+// ErrNoReadings — у пользователя нет ни бесплатных, ни оплаченных раскладов.
+var ErrNoReadings = errors.New("нет доступных раскладов")
+
+// Payment — успешный платёж пользователя.
+type Payment struct {
+	ChargeID         string    `json:"charge_id"`                    // telegram_payment_charge_id
+	ProviderChargeID string    `json:"provider_charge_id,omitempty"` // provider_payment_charge_id
+	TariffID         string    `json:"tariff_id"`
+	Amount           int       `json:"amount"`   // В минимальных единицах валюты
+	Currency         string    `json:"currency"` // Код валюты ISO 4217 или XTR для Telegram Stars
+	Time             time.Time `json:"time"`
+	Refunded         bool      `json:"refunded,omitempty"` // Платёж возвращён, тариф списан с баланса
+	// Что зачислено за платёж: при возврате списывается именно это, даже если тариф в каталоге изменился.
+	Readings int `json:"readings,omitempty"` // Купленные расклады
+	Days     int `json:"days,omitempty"`     // Дни подписки
+}
+
+// Account — баланс пользователя: купленные расклады, подписка и бесплатные расклады за день.
+type Account struct {
+	Readings          int       `json:"readings"`                     // Оплаченные расклады на балансе
+	SubscriptionUntil time.Time `json:"subscription_until,omitempty"` // До какого момента действует подписка
+	FreeDay           string    `json:"free_day,omitempty"`           // День (ГГГГ-ММ-ДД), за который считаются бесплатные расклады
+	FreeUsed          int       `json:"free_used,omitempty"`          // Сколько бесплатных раскладов использовано в FreeDay
+	Payments          []Payment `json:"payments,omitempty"`
+}
+
+// Subscribed сообщает, действует ли подписка в момент now.
+func (a Account) Subscribed(now time.Time) bool {
+	return now.Before(a.SubscriptionUntil)
+}
+
+// FreeLeft возвращает, сколько бесплатных раскладов осталось в день now при норме freeDaily.
+func (a Account) FreeLeft(now time.Time, freeDaily int) int {
+	if a.FreeDay != ledgerDay(now) {
+		return freeDaily
+	}
+	return max(0, freeDaily-a.FreeUsed)
+}
+
+// Функция ledgerDay возвращает день, к которому относится момент now, в виде ГГГГ-ММ-ДД.
+// Бесплатные расклады обновляются в полночь по времени сервера.
+func ledgerDay(now time.Time) string {
+	return now.Format("2006-01-02")
+}
+
+// Ledger — балансы пользователей (ключ — ID пользователя Telegram).
+// Хранится в памяти и сбрасывается в JSON-файл после каждого изменения.
+type Ledger struct {
+	mu        sync.Mutex
+	path      string // Файл баланса (пусто — только в памяти)
+	freeDaily int
+	accounts  map[int64]*Account
+}
+
+// Функция NewLedger открывает баланс в файле path с дневной нормой бесплатных раскладов freeDaily.
+func NewLedger(path string, freeDaily int) (*Ledger, error) {
+	l := &Ledger{path: path, freeDaily: freeDaily, accounts: make(map[int64]*Account)}
+	if path == "" {
+		return l, nil
+	}
+
+	if err := loadJSONFile(path, &l.accounts); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// FreeDaily возвращает дневную норму бесплатных раскладов.
+func (l *Ledger) FreeDaily() int {
+	return l.freeDaily
+}
+
+// Account возвращает копию баланса пользователя.
+func (l *Ledger) Account(userID int64) Account {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if account, ok := l.accounts[userID]; ok {
+		result := *account
+		result.Payments = append([]Payment(nil), account.Payments...)
+		return result
+	}
+	return Account{}
+}
+
+// Allowance сообщает, чем будет оплачен следующий расклад пользователя:
+// сначала подпиской, затем бесплатными раскладами дня, затем купленными.
+// Возвращает ErrNoReadings, если расклад сделать нельзя.
+func (l *Ledger) Allowance(userID int64, now time.Time) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	account := l.accounts[userID]
+	if account == nil {
+		account = &Account{}
+	}
+	return l.allowance(account, now)
+}
+
+// allowance выбирает источник оплаты расклада. Вызывается под блокировкой.
+func (l *Ledger) allowance(account *Account, now time.Time) (string, error) {
+	switch {
+	case account.Subscribed(now):
+		return ReadingSubscription, nil
+	case account.FreeLeft(now, l.freeDaily) > 0:
+		return ReadingFree, nil
+	case account.Readings > 0:
+		return ReadingPaid, nil
+	}
+	return "", ErrNoReadings
+}
+
+// Consume списывает один расклад пользователя и возвращает, чем он был оплачен.
+func (l *Ledger) Consume(userID int64, now time.Time) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var source string
+	err := l.change(userID, func(account *Account) error {
+		var err error
+		source, err = l.allowance(account, now)
+		if err != nil {
+			return err
+		}
+		switch source {
+		case ReadingFree:
+			if day := ledgerDay(now); account.FreeDay != day {
+				account.FreeDay, account.FreeUsed = day, 0
+			}
+			account.FreeUsed++
+		case ReadingPaid:
+			account.Readings--
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+// Credit зачисляет пользователю купленный тариф. Повторное зачисление того же платежа
+// (Telegram может прислать его дважды) ничего не меняет.
+func (l *Ledger) Credit(userID int64, tariff Tariff, payment Payment) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, p := range l.account(userID).Payments {
+		if p.ChargeID == payment.ChargeID {
+			return nil
+		}
+	}
+
+	return l.change(userID, func(account *Account) error {
+		switch tariff.Kind {
+		case TariffReadings:
+			account.Readings += tariff.Readings
+			payment.Readings = tariff.Readings
+		case TariffSubscription:
+			// Новая подписка продлевает действующую, а не начинается заново
+			start := payment.Time
+			if account.SubscriptionUntil.After(start) {
+				start = account.SubscriptionUntil
+			}
+			account.SubscriptionUntil = start.AddDate(0, 0, tariff.Days)
+			payment.Days = tariff.Days
+		}
+		account.Payments = append(account.Payments, payment)
+		return nil
+	})
+}
+
+// FindPayment находит платёж по telegram_payment_charge_id и возвращает его вместе с ID пользователя.
+func (l *Ledger) FindPayment(chargeID string) (int64, Payment, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for userID, account := range l.accounts {
+		for _, payment := range account.Payments {
+			if payment.ChargeID == chargeID {
+				return userID, payment, true
+			}
+		}
+	}
+	return 0, Payment{}, false
+}
+
+// Refund отмечает платёж возвращённым и списывает с баланса то, что было за него зачислено:
+// купленные расклады (сколько их осталось) или дни подписки. Для платежей, записанных
+// до того, как баланс стал запоминать зачисленное, списывается тариф legacy из каталога.
+func (l *Ledger) Refund(userID int64, chargeID string, legacy Tariff) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.change(userID, func(account *Account) error {
+		for i := range account.Payments {
+			payment := &account.Payments[i]
+			if payment.ChargeID != chargeID {
+				continue
+			}
+			if payment.Refunded {
+				return fmt.Errorf("платёж %s уже возвращён", chargeID)
+			}
+			payment.Refunded = true
+			readings, days := payment.Readings, payment.Days
+			if readings == 0 && days == 0 {
+				switch legacy.Kind {
+				case TariffReadings:
+					readings = legacy.Readings
+				case TariffSubscription:
+					days = legacy.Days
+				}
+			}
+			account.Readings -= min(account.Readings, readings)
+			account.SubscriptionUntil = account.SubscriptionUntil.AddDate(0, 0, -days)
+			return nil
+		}
+		return fmt.Errorf("платёж %s не найден", chargeID)
+	})
+}
+
+// change изменяет баланс пользователя функцией fn и записывает балансы на диск.
+// Если fn вернула ошибку или запись не удалась, баланс возвращается в прежнее состояние,
+// чтобы память и файл не расходились. Вызывается под блокировкой.
+func (l *Ledger) change(userID int64, fn func(account *Account) error) error {
+	account := l.account(userID)
+	backup := *account
+	backup.Payments = append([]Payment(nil), account.Payments...)
+	if err := fn(account); err != nil {
+		*account = backup
+		return err
+	}
+	if err := l.flush(); err != nil {
+		*account = backup
+		return err
+	}
+	return nil
+}
+
+// account возвращает баланс пользователя, создавая его при необходимости. Вызывается под блокировкой.
+func (l *Ledger) account(userID int64) *Account {
+	account, ok := l.accounts[userID]
+	if !ok {
+		account = &Account{}
+		l.accounts[userID] = account
+	}
+	return account
+}
+
+// flush записывает балансы на диск. Вызывается под блокировкой.
+func (l *Ledger) flush() error {
+	if l.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(l.accounts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации баланса: %v", err)
+	}
+	return writeFileAtomic(l.path, data)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	testPack  = Tariff{ID: "pack5", Kind: TariffReadings, Readings: 5}
+	testMonth = Tariff{ID: "month", Kind: TariffSubscription, Days: 30}
+)
+
+func TestLedgerConsumeOrder(t *testing.T) {
+	l, err := NewLedger("", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	if err := l.Credit(1, testPack, Payment{ChargeID: "c1", Time: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сначала бесплатный расклад дня, затем купленные
+	for i, want := range []string{ReadingFree, ReadingPaid, ReadingPaid} {
+		source, err := l.Consume(1, now)
+		if err != nil || source != want {
+			t.Fatalf("расклад %d: %q, %v; ожидалось %q", i+1, source, err, want)
+		}
+	}
+	if got := l.Account(1).Readings; got != 3 {
+		t.Fatalf("осталось %d раскладов, ожидалось 3", got)
+	}
+
+	// На следующий день бесплатный расклад снова доступен
+	if source, _ := l.Consume(1, now.AddDate(0, 0, 1)); source != ReadingFree {
+		t.Fatalf("на следующий день списан %q, ожидался бесплатный", source)
+	}
+
+	// Пустой баланс
+	if _, err := l.Consume(2, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Consume(2, now); !errors.Is(err, ErrNoReadings) {
+		t.Fatalf("ожидалась ErrNoReadings, получено %v", err)
+	}
+}
+
+func TestLedgerCreditIdempotentAndSubscription(t *testing.T) {
+	l, _ := NewLedger("", 0)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	for i := 0; i < 2; i++ {
+		if err := l.Credit(1, testPack, Payment{ChargeID: "c1", Time: now}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if account := l.Account(1); account.Readings != 5 || len(account.Payments) != 1 {
+		t.Fatalf("повторный платёж зачислен дважды: %+v", account)
+	}
+
+	// Вторая подписка продлевает первую
+	l.Credit(1, testMonth, Payment{ChargeID: "m1", Time: now})
+	l.Credit(1, testMonth, Payment{ChargeID: "m2", Time: now.Add(time.Hour)})
+	if want := now.AddDate(0, 0, 60); !l.Account(1).SubscriptionUntil.Equal(want) {
+		t.Fatalf("подписка до %v, ожидалось %v", l.Account(1).SubscriptionUntil, want)
+	}
+	if source, _ := l.Consume(1, now); source != ReadingSubscription {
+		t.Fatalf("списан %q, ожидалась подписка", source)
+	}
+}
+
+func TestLedgerRefund(t *testing.T) {
+	l, _ := NewLedger("", 0)
+	now := time.Now()
+	l.Credit(1, testPack, Payment{ChargeID: "c1", Time: now})
+	l.Consume(1, now)
+
+	if err := l.Refund(1, "c1", testPack); err != nil {
+		t.Fatal(err)
+	}
+	account := l.Account(1)
+	if account.Readings != 0 || !account.Payments[0].Refunded {
+		t.Fatalf("после возврата: %+v", account)
+	}
+	if err := l.Refund(1, "c1", testPack); err == nil {
+		t.Fatal("повторный возврат должен завершиться ошибкой")
+	}
+	if err := l.Refund(1, "unknown", testPack); err == nil {
+		t.Fatal("возврат неизвестного платежа должен завершиться ошибкой")
+	}
+}
+
+// Возврат списывает то, что было зачислено за платёж, даже если тариф в каталоге с тех пор изменился.
+func TestLedgerRefundUsesCreditedAmounts(t *testing.T) {
+	l, _ := NewLedger("", 0)
+	now := time.Now()
+	l.Credit(1, testPack, Payment{ChargeID: "c1", Time: now})
+	l.Credit(1, testPack, Payment{ChargeID: "c2", Time: now})
+	l.Credit(1, testMonth, Payment{ChargeID: "m1", Time: now})
+
+	biggerPack, longerMonth := testPack, testMonth
+	biggerPack.Readings *= 10
+	longerMonth.Days *= 10
+	if err := l.Refund(1, "c1", biggerPack); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Refund(1, "m1", longerMonth); err != nil {
+		t.Fatal(err)
+	}
+	account := l.Account(1)
+	if account.Readings != testPack.Readings {
+		t.Errorf("после возврата одного пакета осталось %d раскладов, ожидалось %d", account.Readings, testPack.Readings)
+	}
+	if !account.SubscriptionUntil.Equal(now) {
+		t.Errorf("после возврата подписки она действует до %v, ожидалось %v", account.SubscriptionUntil, now)
+	}
+}
+
+// Платёж, записанный до появления полей readings/days, возвращается по тарифу из каталога.
+func TestLedgerRefundLegacyPayment(t *testing.T) {
+	l, _ := NewLedger("", 0)
+	l.accounts[1] = &Account{Readings: testPack.Readings, Payments: []Payment{{ChargeID: "old", TariffID: testPack.ID}}}
+	if err := l.Refund(1, "old", testPack); err != nil {
+		t.Fatal(err)
+	}
+	if account := l.Account(1); account.Readings != 0 {
+		t.Errorf("после возврата старого платежа осталось %d раскладов", account.Readings)
+	}
+}
+
+// Если баланс не удалось записать на диск, изменение в памяти откатывается.
+func TestLedgerRollbackOnFlushError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	l, err := NewLedger(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// На месте временного файла — каталог, поэтому запись всегда завершается ошибкой
+	if err := os.Mkdir(path+".tmp", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Credit(1, testPack, Payment{ChargeID: "c1", Time: time.Now()}); err == nil {
+		t.Fatal("ожидалась ошибка записи")
+	}
+	if account := l.Account(1); account.Readings != 0 || len(account.Payments) != 0 {
+		t.Fatalf("баланс изменился, хотя не был записан: %+v", account)
+	}
+	if _, err := l.Consume(1, time.Now()); err == nil {
+		t.Fatal("ожидалась ошибка записи")
+	}
+	if account := l.Account(1); account.FreeUsed != 0 {
+		t.Fatalf("бесплатный расклад списан, хотя баланс не был записан: %+v", account)
+	}
+}
+
+// Баланс переживает перезапуск: новый Ledger читает то, что записал прежний.
+func TestLedgerPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.json")
+	l, _ := NewLedger(path, 1)
+	if err := l.Credit(7, testPack, Payment{ChargeID: "c1", Time: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewLedger(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Account(7).Readings; got != 5 {
+		t.Fatalf("после перезапуска %d раскладов, ожидалось 5", got)
+	}
+}
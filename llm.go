@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// Структура запроса к DeepSeek API (Ollama, эндпоинт completions)
+type DeepSeekRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream,omitempty"` // Получать ответ потоком (SSE)
+}
+
+// Структура ответа от DeepSeek API
+type DeepSeekResponse struct {
+	Choices []struct {
+		Text string `json:"text"`
+	} `json:"choices"`
+}
+
+// OllamaInterpreter обращается к устаревшему эндпоинту completions в Ollama.
+// Ролей этот эндпоинт не понимает, поэтому системный запрос и история склеиваются в одну строку.
+type OllamaInterpreter struct {
+	URL    string // Например, http://localhost:11434/v1/completions
+	Model  string // Например, deepseek-r1:32b
+	Client *LLMClient
+}
+
+func (o *OllamaInterpreter) Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error) {
+	var dsResp DeepSeekResponse
+	if err := o.Client.PostJSON(ctx, o.URL, "", DeepSeekRequest{Model: o.Model, Prompt: req.FlatPrompt()}, &dsResp); err != nil {
+		return Interpretation{}, err
+	}
+
+	// Проверяем, есть ли текст в `choices`
+	if len(dsResp.Choices) == 0 {
+		return Interpretation{}, fmt.Errorf("модель %s не вернула текстовый ответ", o.Model)
+	}
+	return Interpretation{Text: stripThinking(dsResp.Choices[0].Text), Model: o.Model}, nil
+}
+
+func (o *OllamaInterpreter) InterpretStream(ctx context.Context, req InterpretRequest, onDelta func(string)) (Interpretation, error) {
+	text, err := o.Client.PostStream(ctx, o.URL, "", DeepSeekRequest{Model: o.Model, Prompt: req.FlatPrompt(), Stream: true}, onDelta)
+	if err != nil {
+		return Interpretation{}, err
+	}
+	return Interpretation{Text: text, Model: o.Model}, nil
+}
+
+// ChatMessage — сообщение в формате chat completions.
+type ChatMessage struct {
+	Role    string `json:"role"` // system, user или assistant
+	Content string `json:"content"`
+}
+
+// Структура запроса к OpenAI-совместимому эндпоинту chat completions
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"` // Получать ответ потоком (SSE)
+}
+
+// Структура ответа от эндпоинта chat completions
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message ChatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// OpenAIChatInterpreter обращается к OpenAI-совместимому эндпоинту chat completions
+// (OpenAI, Ollama и другие) с ролями system, user и assistant.
+type OpenAIChatInterpreter struct {
+	URL    string // Например, https://api.openai.com/v1/chat/completions
+	Model  string
+	APIKey string // Пусто для локальных моделей без авторизации
+	Client *LLMClient
+}
+
+func (o *OpenAIChatInterpreter) Interpret(ctx context.Context, req InterpretRequest) (Interpretation, error) {
+	body := chatCompletionRequest{
+		Model:    o.Model,
+		Messages: req.Messages(),
+	}
+	var chatResp chatCompletionResponse
+	if err := o.Client.PostJSON(ctx, o.URL, o.APIKey, body, &chatResp); err != nil {
+		return Interpretation{}, err
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return Interpretation{}, fmt.Errorf("модель %s не вернула текстовый ответ", o.Model)
+	}
+	return Interpretation{Text: stripThinking(chatResp.Choices[0].Message.Content), Model: o.Model}, nil
+}
+
+func (o *OpenAIChatInterpreter) InterpretStream(ctx context.Context, req InterpretRequest, onDelta func(string)) (Interpretation, error) {
+	body := chatCompletionRequest{
+		Model:    o.Model,
+		Messages: req.Messages(),
+		Stream:   true,
+	}
+	text, err := o.Client.PostStream(ctx, o.URL, o.APIKey, body, onDelta)
+	if err != nil {
+		return Interpretation{}, err
+	}
+	return Interpretation{Text: text, Model: o.Model}, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen возвращается, когда автомат разомкнут и запросы к модели временно не отправляются.
+var ErrCircuitOpen = errors.New("модель временно недоступна: автомат разомкнут")
+
+// ErrModelSlow — причина отмены запроса, когда модель не начала отвечать за отведённое время.
+// Такая отмена, как и истёкший дедлайн, считается неудачей модели.
+var ErrModelSlow = errors.New("модель не начала отвечать вовремя")
+
+// HTTPStatusError — ответ модели с кодом, отличным от 2xx.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string // Начало тела ответа для логов
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("модель ответила %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// retryable сообщает, имеет ли смысл повторить запрос: ошибки сервера и 429 — временные.
+func (e *HTTPStatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests
+}
+
+// LLMClient — HTTP-клиент для языковых моделей с таймаутами подключения,
+// повторами с экспоненциальной задержкой и автоматом, который перестаёт
+// дёргать модель после серии неудач.
+type LLMClient struct {
+	http    *http.Client
+	retries int           // Сколько раз повторять запрос после первой неудачной попытки
+	backoff time.Duration // Базовая задержка перед повтором, удваивается с каждой попыткой
+	breaker *CircuitBreaker
+}
+
+// Функция newLLMClient создаёт клиент по настройкам:
+// LLM_CONNECT_TIMEOUT — таймаут подключения, LLM_RETRIES и LLM_RETRY_BACKOFF — повторы,
+// LLM_BREAKER_THRESHOLD и LLM_BREAKER_COOLDOWN — автомат.
+func newLLMClient() (*LLMClient, error) {
+	connectTimeout, err := envDuration("LLM_CONNECT_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	retries, err := envInt("LLM_RETRIES", 2)
+	if err != nil {
+		return nil, err
+	}
+	backoff, err := envDuration("LLM_RETRY_BACKOFF", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := envInt("LLM_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+	cooldown, err := envDuration("LLM_BREAKER_COOLDOWN", time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Общий дедлайн запроса задаёт контекст: у потокового ответа тело читается долго,
+	// поэтому http.Client.Timeout здесь не подходит.
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: connectTimeout}).DialContext,
+		TLSHandshakeTimeout: connectTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &LLMClient{
+		http:    &http.Client{Transport: transport},
+		retries: retries,
+		backoff: backoff,
+		breaker: NewCircuitBreaker(threshold, cooldown),
+	}, nil
+}
+
+// PostJSON отправляет JSON-запрос и декодирует JSON-ответ в out.
+// Если apiKey не пуст, он передаётся в заголовке Authorization.
+func (c *LLMClient) PostJSON(ctx context.Context, url, apiKey string, in, out interface{}) error {
+	resp, err := c.Post(ctx, url, apiKey, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Декодируем JSON-ответ
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("ошибка декодирования JSON: %v", err)
+	}
+	return nil
+}
+
+// PostStream отправляет JSON-запрос и читает потоковый ответ,
+// передавая видимые фрагменты текста в onDelta. Возвращает весь видимый текст.
+// Повторы возможны только до начала потока: оборванный на середине ответ не повторяется.
+func (c *LLMClient) PostStream(ctx context.Context, url, apiKey string, in interface{}, onDelta func(string)) (string, error) {
+	resp, err := c.Post(ctx, url, apiKey, in)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return streamVisible(resp.Body, onDelta)
+}
+
+// Post отправляет JSON-запрос с повторами и возвращает успешный (2xx) HTTP-ответ.
+func (c *LLMClient) Post(ctx context.Context, url, apiKey string, in interface{}) (*http.Response, error) {
+	// Формируем JSON-запрос
+	reqBody, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка формирования запроса: %v", err)
+	}
+
+	if !c.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	// Запрос, который не закончился ни успехом, ни неудачей модели (отмена, ошибка 4xx),
+	// всё равно должен освободить пробный запрос, иначе автомат не замкнётся никогда
+	resolved := false
+	defer func() {
+		if !resolved {
+			c.breaker.Release()
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, url, apiKey, reqBody)
+		if err == nil {
+			c.breaker.Success()
+			resolved = true
+			return resp, nil
+		}
+
+		// После отмены повторять бессмысленно. Истёкший дедлайн или долгое молчание модели —
+		// её неудача, а отмена по инициативе вызывающего (например, при остановке бота) автомат не трогает
+		if ctx.Err() != nil {
+			if modelTimedOut(ctx) {
+				c.breaker.Failure()
+				resolved = true
+			}
+			return nil, err
+		}
+		var statusErr *HTTPStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			// Ошибка в самом запросе (4xx): модель жива, автомат не трогаем
+			return nil, err
+		}
+		if attempt >= c.retries {
+			c.breaker.Failure()
+			resolved = true
+			return nil, err
+		}
+
+		delay := c.backoff << attempt
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1)) // Случайная добавка, чтобы повторы не шли волной
+		log.Printf("Запрос к модели не удался (попытка %d из %d): %v, повтор через %v", attempt+1, c.retries+1, err, delay)
+
+		select {
+		case <-ctx.Done():
+			if modelTimedOut(ctx) {
+				c.breaker.Failure()
+				resolved = true
+			}
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Функция modelTimedOut сообщает, отменён ли ctx из-за того, что модель не ответила вовремя:
+// истёк дедлайн или запрос прерван с причиной ErrModelSlow.
+func modelTimedOut(ctx context.Context) bool {
+	cause := context.Cause(ctx)
+	return errors.Is(cause, context.DeadlineExceeded) || errors.Is(cause, ErrModelSlow)
+}
+
+// do выполняет одну попытку запроса.
+func (c *LLMClient) do(ctx context.Context, url, apiKey string, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка формирования запроса: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	// Отправляем HTTP-запрос
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка отправки запроса: %v", err)
+	}
+
+	// Проверяем код ответа
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(snippet)}
+	}
+	return resp, nil
+}
+
+// CircuitBreaker — автомат: после threshold неудач подряд размыкается на cooldown,
+// затем пропускает один пробный запрос и по его результату замыкается или снова размыкается.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	failures  int       // Неудач подряд
+	openUntil time.Time // До какого момента автомат разомкнут
+	probing   bool      // Пробный запрос уже отправлен
+}
+
+// NewCircuitBreaker создаёт автомат. threshold <= 0 отключает его.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow сообщает, можно ли сейчас отправить запрос.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	// Время ожидания вышло — пропускаем один пробный запрос
+	b.probing = true
+	return true
+}
+
+// Success отмечает успешный запрос и замыкает автомат.
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+// Release освобождает пробный запрос, который закончился без ответа модели (например, был отменён).
+// Автомат снова размыкается на cooldown, после чего пропустит новый пробный запрос.
+// Если пробного запроса не было, ничего не меняется.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.probing {
+		return
+	}
+	b.probing = false
+	b.openUntil = time.Now().Add(b.cooldown)
+}
+
+// Failure отмечает неудачный запрос и при достижении порога размыкает автомат.
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		log.Printf("Автомат запросов к модели разомкнут до %s после %d неудач подряд", b.openUntil.Format(time.TimeOnly), b.failures)
+	}
+}
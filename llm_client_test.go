@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Пробный запрос, отменённый до ответа модели, не должен навсегда оставлять автомат разомкнутым.
+func TestCircuitBreakerReleasesCancelledProbe(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	client := &LLMClient{http: srv.Client(), breaker: NewCircuitBreaker(1, 10*time.Millisecond)}
+	client.breaker.Failure() // Автомат разомкнут
+	time.Sleep(20 * time.Millisecond)
+
+	// Отмена вызывающим, а не дедлайн: такая отмена не считается неудачей модели
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := client.Post(ctx, srv.URL, "", map[string]string{}); err == nil {
+		t.Fatal("ожидалась ошибка отменённого запроса")
+	}
+	if client.breaker.Allow() {
+		t.Fatal("сразу после отменённой пробы автомат должен быть разомкнут")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if !client.breaker.Allow() {
+		t.Fatal("после cooldown автомат должен пропустить новый пробный запрос")
+	}
+}
+
+// Ошибка формирования запроса не должна занимать пробный запрос.
+func TestCircuitBreakerMarshalErrorKeepsProbe(t *testing.T) {
+	client := &LLMClient{http: http.DefaultClient, breaker: NewCircuitBreaker(1, 0)}
+	client.breaker.Failure()
+	if _, err := client.Post(context.Background(), "http://127.0.0.1:0", "", make(chan int)); err == nil {
+		t.Fatal("ожидалась ошибка формирования запроса")
+	}
+	if !client.breaker.Allow() {
+		t.Fatal("пробный запрос должен остаться свободным")
+	}
+}
+
+// Истёкший дедлайн и отмена из-за молчания модели — неудачи: они размыкают автомат,
+// и следующие расклады сразу получают запасное толкование, не дожидаясь SlowAfter.
+func TestCircuitBreakerCountsTimeouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel func(ctx context.Context) (context.Context, context.CancelFunc)
+	}{
+		{"дедлайн", func(ctx context.Context) (context.Context, context.CancelFunc) {
+			return context.WithTimeout(ctx, 20*time.Millisecond)
+		}},
+		{"модель молчит", func(ctx context.Context) (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancelCause(ctx)
+			time.AfterFunc(20*time.Millisecond, func() { cancel(ErrModelSlow) })
+			return ctx, func() { cancel(nil) }
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Тело нужно дочитать: только после этого сервер замечает, что клиент отключился
+				io.Copy(io.Discard, r.Body)
+				<-r.Context().Done()
+			}))
+			defer srv.Close()
+
+			client := &LLMClient{http: srv.Client(), breaker: NewCircuitBreaker(1, time.Minute)}
+			ctx, cancel := tt.cancel(context.Background())
+			defer cancel()
+			if _, err := client.Post(ctx, srv.URL, "", map[string]string{}); err == nil {
+				t.Fatal("ожидалась ошибка")
+			}
+			if client.breaker.Allow() {
+				t.Fatal("после неответа модели автомат должен разомкнуться")
+			}
+		})
+	}
+}
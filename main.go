@@ -3,15 +3,15 @@ package main // Основной пакет приложения
 import (
 	// Стандартная библиотека для логирования
 
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	log "log"
-	"math/rand"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	// Библиотека для работы с Telegram Bot API
@@ -19,22 +19,201 @@ import (
 	"github.com/joho/godotenv"
 )
 
-// Глобальная переменная для хранения ID последнего сообщения, отправленного ботом.
-// Используется для удаления предыдущего сообщения бота перед отправкой нового.
-var lastBotMessageID int
+// Глобальное хранилище колоды: загружается при запуске и перезагружается при изменении файла.
+var deckRepo *DeckRepository
 
-// Глобальная мапа для хранения состояния каждого чата (ключ — ID чата, значение — строка состояния).
-// Возможные состояния: "main", "question", "instruction", "tariffs".
-// "main" — главное меню; "question" — режим для ввода вопроса; "instruction"/"tariffs" — режимы просмотра инструкций и тарифов.
-var userState = make(map[int64]string)
+// Глобальный генератор раскладов. По умолчанию зёрна криптографические,
+// переменная DRAW_SEED делает последовательность раскладов воспроизводимой.
+var drawer *Drawer
+
+// Глобальный толкователь раскладов, выбирается переменной LLM_PROVIDER.
+var interpreter Interpreter
+
+// Глобальные настройки потоковой выдачи ответа: включена ли она (LLM_STREAM)
+// и как часто обновлять сообщение с ответом (STREAM_EDIT_INTERVAL).
+var (
+	streamAnswers      bool
+	streamEditInterval time.Duration
+)
+
+// Глобальный дедлайн на толкование одного расклада (LLM_TIMEOUT), включая все повторы.
+var llmTimeout time.Duration
+
+// Глобальный список ID администраторов (переменная ADMIN_IDS через запятую).
+// Администраторам доступны служебные команды, например /reload_deck.
+var adminIDs = make(map[int64]bool)
+
+// Глобальный набор раскладов, загружается из spreads.json при запуске.
+var spreadBook *SpreadBook
+
+// Глобальная библиотека шаблонов запросов к модели, загружается из каталога prompts при запуске.
+var prompts *PromptLibrary
+
+// Глобальный флаг режима чистого чата (переменная среды CLEAN_CHAT).
+// В этом режиме бот удаляет сообщение пользователя и свой предыдущий ответ в чате.
+var cleanChat bool
+
+// Глобальное хранилище сессий чатов (ключ — ID чата, значение — состояние диалога).
+// Инициализируется в main в зависимости от переменной SESSION_STORE.
+var sessions SessionStore
 
 // Функция main — точка входа в программу.
 func main() {
+	// Если передана подкоманда (например, migrate-deck), выполняем её вместо запуска бота.
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Получаем API-ключ бота из переменной среды
 	TELEGRAM_BOT_TOKEN := importEnv("hiddenFiles.env", "TELEGRAM_BOT_TOKEN")
 
+	// Загружаем и проверяем колоду: с неполной или повреждённой колодой бот не запускается.
+	repo, err := NewDeckRepository(envOrDefault("DECK_FILE", "tarocards.json"))
+	if err != nil {
+		log.Fatalf("Ошибка загрузки колоды: %v", err)
+	}
+	deckRepo = repo
+
+	// Создаём генератор раскладов.
+	drawer = NewDrawer()
+	if value := envOrDefault("DRAW_SEED", ""); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Fatalf("Некорректное значение DRAW_SEED: %v", err)
+		}
+		drawer = NewSeededDrawer(seed)
+	}
+
+	// Читаем ограничение на число уточняющих вопросов к раскладу.
+	maxFollowUps, err = envInt("MAX_FOLLOWUPS", maxFollowUps)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Создаём толкователя раскладов.
+	interp, err := newInterpreter()
+	if err != nil {
+		log.Fatalf("Ошибка настройки толкователя: %v", err)
+	}
+	interpreter = interp
+
+	// Читаем настройки потоковой выдачи ответа.
+	streamAnswers, err = strconv.ParseBool(envOrDefault("LLM_STREAM", "true"))
+	if err != nil {
+		log.Fatalf("Некорректное значение LLM_STREAM: %v", err)
+	}
+	streamEditInterval, err = time.ParseDuration(envOrDefault("STREAM_EDIT_INTERVAL", "1500ms"))
+	if err != nil {
+		log.Fatalf("Некорректное значение STREAM_EDIT_INTERVAL: %v", err)
+	}
+	llmTimeout, err = envDuration("LLM_TIMEOUT", 3*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Читаем список администраторов.
+	for _, field := range strings.Split(envOrDefault("ADMIN_IDS", ""), ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			log.Fatalf("Некорректный ID администратора %q: %v", field, err)
+		}
+		adminIDs[id] = true
+	}
+
+	// Загружаем описания раскладов. Без них бот не может работать, поэтому ошибка фатальна.
+	book, err := loadSpreads(envOrDefault("SPREADS_FILE", "spreads.json"))
+	if err != nil {
+		log.Fatalf("Ошибка загрузки раскладов: %v", err)
+	}
+	spreadBook = book
+
+	// Загружаем шаблоны запросов и проверяем, что у каждого расклада есть свой шаблон.
+	lib, err := loadPromptLibrary(envOrDefault("PROMPTS_DIR", "prompts"))
+	if err != nil {
+		log.Fatalf("Ошибка загрузки шаблонов запросов: %v", err)
+	}
+	if err := lib.CheckSpreads(spreadBook); err != nil {
+		log.Fatalf("Ошибка проверки шаблонов запросов: %v", err)
+	}
+	prompts = lib
+	promptPersona = envOrDefault("LLM_PERSONA", promptPersona)
+	log.Printf("Загружены шаблоны запросов: %s", strings.Join(prompts.Versions(), ", "))
+
+	// Открываем хранилище сессий, чтобы состояние чатов переживало перезапуск.
+	store, err := newSessionStore()
+	if err != nil {
+		log.Fatalf("Ошибка открытия хранилища сессий: %v", err)
+	}
+	sessions = store
+
+	// Загружаем каталог тарифов, открываем баланс пользователей и ограничения частоты запросов.
+	catalog, err := loadTariffs(envOrDefault("TARIFFS_FILE", "tariffs.json"))
+	if err != nil {
+		log.Fatalf("Ошибка загрузки тарифов: %v", err)
+	}
+	tariffs = catalog
+	accounts, err := NewLedger(envOrDefault("LEDGER_FILE", "data/ledger.json"), tariffs.FreeDaily)
+	if err != nil {
+		log.Fatalf("Ошибка открытия баланса пользователей: %v", err)
+	}
+	ledger = accounts
+	limits, err := NewQuotas(envOrDefault("QUOTA_FILE", "data/quotas.json"), ledger, tariffs.RateLimits)
+	if err != nil {
+		log.Fatalf("Ошибка открытия ограничений запросов: %v", err)
+	}
+	quotas = limits
+	paymentProviderToken = os.Getenv("PAYMENT_PROVIDER_TOKEN")
+	starsPayments, err = strconv.ParseBool(envOrDefault("STARS_PAYMENTS", "true"))
+	if err != nil {
+		log.Fatalf("Некорректное значение STARS_PAYMENTS: %v", err)
+	}
+
+	// Открываем кэш file_id изображений карт, чтобы не загружать одни и те же файлы повторно.
+	imageCache, err := NewCardImageCache(envOrDefault("CARD_IMAGE_CACHE", "data/card_images.json"))
+	if err != nil {
+		log.Fatalf("Ошибка открытия кэша изображений карт: %v", err)
+	}
+	cardImages = imageCache
+
+	// Загружаем инструкцию и подставляем в неё расклады и условия оплаты.
+	var menuSpreads []Spread
+	for _, spread := range spreadBook.Spreads {
+		if spread.Button != "" {
+			menuSpreads = append(menuSpreads, spread)
+		}
+	}
+	g, err := loadGuide(envOrDefault("INSTRUCTION_FILE", "instruction.json"), guideData{
+		Spreads:     menuSpreads,
+		Default:     spreadBook.Default(),
+		FreeDaily:   tariffs.FreeDaily,
+		FollowUps:   maxFollowUps,
+		MaxQuestion: maxQuestionLength,
+	})
+	if err != nil {
+		log.Fatalf("Ошибка загрузки инструкции: %v", err)
+	}
+	guide = g
+
+	// Открываем хранилище истории раскладов.
+	historyStore, err := newHistoryStore()
+	if err != nil {
+		log.Fatalf("Ошибка открытия истории раскладов: %v", err)
+	}
+	history = historyStore
+
+	// Читаем настройку режима чистого чата.
+	cleanChat, err = strconv.ParseBool(envOrDefault("CLEAN_CHAT", "false"))
+	if err != nil {
+		log.Fatalf("Некорректное значение CLEAN_CHAT: %v", err)
+	}
+
 	// Создаем нового бота, используя ваш уникальный токен.
-	bot, err := tgbotapi.NewBotAPI(TELEGRAM_BOT_TOKEN)
+	// TELEGRAM_API_ENDPOINT позволяет направить запросы на собственный или тестовый сервер Bot API,
+	// например "http://localhost:8081/bot%s/%s".
+	bot, err := tgbotapi.NewBotAPIWithClient(TELEGRAM_BOT_TOKEN, envOrDefault("TELEGRAM_API_ENDPOINT", tgbotapi.APIEndpoint), &http.Client{})
 	// Если произошла ошибка (например, неверный токен), логируем ошибку и завершаем выполнение.
 	if err != nil {
 		log.Panic(err)
@@ -55,159 +234,230 @@ func main() {
 	// Получаем канал, по которому будут поступать обновления (новые сообщения).
 	updates := bot.GetUpdatesChan(updateConfig)
 
-	// Бесконечный цикл для обработки каждого обновления.
-	for update := range updates {
-		// Если обновление содержит сообщение (а не, например, callback-запрос), то:
+	// Обновления обрабатываются пулом воркеров: разные чаты — параллельно,
+	// сообщения одного чата — строго по порядку. Размер пула задаётся переменной WORKERS.
+	workers, err := strconv.Atoi(envOrDefault("WORKERS", "8"))
+	if err != nil {
+		log.Fatalf("Некорректное значение WORKERS: %v", err)
+	}
+	dispatcher := NewDispatcher(workers, func(update tgbotapi.Update) {
+		// Если обновление содержит сообщение, передаем его в функцию handleMessage для обработки.
 		if update.Message != nil {
-			// Передаем сообщение в функцию handleMessage для обработки.
 			handleMessage(bot, update.Message)
 		}
+		// Нажатия на inline-кнопки обрабатываются отдельно.
+		if update.CallbackQuery != nil {
+			handleCallback(bot, update.CallbackQuery)
+		}
+		// Перед списанием денег Telegram спрашивает, можно ли принять платёж.
+		if update.PreCheckoutQuery != nil {
+			handlePreCheckout(bot, update.PreCheckoutQuery)
+		}
+	})
+
+	// При получении сигнала завершения перестаём принимать обновления,
+	// канал updates закрывается и цикл ниже завершается.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		log.Println("Получен сигнал завершения, останавливаем бота...")
+		bot.StopReceivingUpdates()
+	}()
+
+	// Следим за файлом колоды и перезагружаем её при изменении.
+	go deckRepo.Watch(ctx, 10*time.Second)
+
+	// Цикл получения обновлений: каждое обновление передаётся диспетчеру.
+	for update := range updates {
+		dispatcher.Dispatch(update)
 	}
+	// Дожидаемся, пока воркеры закончат уже принятые обновления.
+	dispatcher.Wait()
 }
 
 // Функция handleMessage обрабатывает входящие сообщения от пользователей.
 func handleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 
-	// // ----------------------- Удаление сообщения пользователя -----------------------
-	// // Создаем объект для удаления сообщения пользователя по ID чата и ID сообщения.
-	// deleteMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
-	// // Отправляем запрос на удаление сообщения пользователя.
-	// bot.Send(deleteMsg)
-
-	// // ----------------------- Удаление предыдущего сообщения бота -----------------------
-	// // Если переменная lastBotMessageID не равна 0, значит бот уже отправлял сообщение.
-	// if lastBotMessageID != 0 {
-	// 	// Создаем запрос на удаление предыдущего сообщения бота.
-	// 	deleteBotMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, lastBotMessageID)
-	// 	// Отправляем запрос на удаление.
-	// 	bot.Send(deleteBotMsg)
-	// }
-
 	// ----------------------- Определение состояния пользователя -----------------------
-	// Извлекаем состояние текущего чата из мапы userState.
-	state, exists := userState[message.Chat.ID]
-	// Если состояние не задано, по умолчанию считаем, что чат в главном меню.
+	// Извлекаем сессию текущего чата из хранилища.
+	session, exists := sessions.Get(message.Chat.ID)
+	// Если сессии нет, по умолчанию считаем, что чат в главном меню.
+	// Новому чату при первом /start покажем инструкцию.
 	if !exists {
-		state = "main"
-		userState[message.Chat.ID] = "main"
+		session.State = "main"
+		session.GuidePending = true
+	}
+	// После обработки сохраняем сессию, в том числе при досрочном выходе из функции.
+	defer func() {
+		if err := sessions.Set(message.Chat.ID, session); err != nil {
+			log.Printf("Ошибка сохранения сессии чата %d: %v", message.Chat.ID, err)
+		}
+	}()
+
+	// ----------------------- Режим чистого чата -----------------------
+	// Удаляем сообщение пользователя и предыдущий ответ бота именно в этом чате.
+	// Без этого режима сбрасываем ID, которые могли остаться в сессии от запуска с CLEAN_CHAT=true.
+	if cleanChat {
+		cleanupChat(bot, message, &session)
+	} else {
+		session.BotMessageIDs = nil
+	}
+
+	// ----------------------- Служебные команды администратора -----------------------
+	// Команда /reload_deck перечитывает колоду из файла в любом состоянии чата.
+	if message.Text == "/reload_deck" && message.From != nil && adminIDs[message.From.ID] {
+		if err := deckRepo.Reload(); err != nil {
+			log.Printf("Ошибка перезагрузки колоды: %v", err)
+			session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Колода не перезагружена, подробности в логах. Используется прежняя колода."))
+		} else {
+			session.rememberBotMessage(sendMessage(bot, message.Chat.ID, fmt.Sprintf("Колода перезагружена: %d карт.", deckRepo.Deck().Len())))
+		}
+		return
+	}
+
+	// Команда /refund <charge_id> возвращает платёж в Telegram Stars.
+	if strings.HasPrefix(message.Text, "/refund") && message.From != nil && adminIDs[message.From.ID] {
+		fields := strings.Fields(message.Text)
+		if len(fields) != 2 {
+			session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Использование: /refund <telegram_payment_charge_id>"))
+		} else {
+			session.rememberBotMessage(sendMessage(bot, message.Chat.ID, refundPayment(bot, fields[1])))
+		}
+		return
+	}
+
+	// ----------------------- Оплата тарифа -----------------------
+	// Сообщение об успешной оплате приходит в любом состоянии чата.
+	if message.SuccessfulPayment != nil {
+		handleSuccessfulPayment(bot, message, &session)
+		return
+	}
+
+	// ----------------------- Команды, доступные в любом состоянии -----------------------
+	// Команда /history показывает историю раскладов пользователя, не меняя состояние чата.
+	if message.Text == "/history" && message.From != nil {
+		session.rememberBotMessage(sendHistory(bot, message.Chat.ID, message.From.ID))
+		return
+	}
+	// Команда /replay <код> заново показывает карты расклада по его коду.
+	if message.Text == "/replay" || strings.HasPrefix(message.Text, "/replay ") {
+		handleReplay(bot, message, &session)
+		return
 	}
 
 	// ----------------------- Обработка сообщения в зависимости от состояния -----------------------
-	switch state {
+	switch session.State {
 	// Состояние "main" — пользователь находится в главном меню.
 	case "main":
 		switch message.Text {
 		// Команда /start инициирует главное меню.
 		case "/start":
+			// При первом запуске показываем инструкцию, чтобы пользователь знал, с чего начать.
+			if session.GuidePending {
+				session.GuidePending = false
+				openMenu(bot, message.Chat.ID, messageUserID(message), &session, menuGuide)
+				break
+			}
 			// Состояние остается "main" и отправляется главное меню.
-			lastBotMessageID = sendMainMenu(bot, message.Chat.ID)
-		// При выборе пункта "🔮 Задать вопрос 🔮" переходим в режим вопроса.
-		case "🔮 Задать вопрос 🔮":
-			// Устанавливаем состояние для данного чата в "question".
-			userState[message.Chat.ID] = "question"
-			// Отправляем меню для вопросов, где пользователь может выбрать вариант или ввести вопрос вручную.
-			lastBotMessageID = sendQuestionMenu(bot, message.Chat.ID)
-		// При выборе "📑 Инструкция 📑" переключаем состояние на "instruction" и отправляем инструкцию.
-		case "📑 Инструкция 📑":
-			userState[message.Chat.ID] = "instruction"
-			lastBotMessageID = sendInstruction(bot, message.Chat.ID)
-		// При выборе "💲Тарифы💲" переключаем состояние на "tariffs" и отправляем описание тарифов.
-		case "💲Тарифы💲":
-			userState[message.Chat.ID] = "tariffs"
-			lastBotMessageID = sendTariffs(bot, message.Chat.ID)
-		// Если нажата кнопка "Назад в меню", просто отправляем главное меню.
-		case "Назад в меню":
-			userState[message.Chat.ID] = "main"
-			lastBotMessageID = sendMainMenu(bot, message.Chat.ID)
-		// Если пользователь отправляет любой другой текст в главном меню, выдаем сообщение об ошибке.
+			session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+		// Пункты меню находятся по подписи в таблице mainMenuItems: "🔮 Задать вопрос 🔮" открывает меню вопросов,
+		// "📚 Значения карт" — справочник, "📑 Инструкция 📑" — инструкцию, "💲Тарифы💲" — тарифы,
+		// "Назад в меню" — снова главное меню.
 		default:
-			lastBotMessageID = sendMessage(bot, message.Chat.ID, "Неизвестная команда. Выберите пункт из меню.")
+			if action, ok := menuActionByLabel(mainMenuItems, message.Text); ok {
+				openMenu(bot, message.Chat.ID, messageUserID(message), &session, action)
+			} else {
+				// Если пользователь отправляет любой другой текст в главном меню, выдаем сообщение об ошибке.
+				session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Неизвестная команда. Выберите пункт из меню."))
+			}
 		}
 
 	// Состояние "question" — пользователь перешёл в режим "🔮 Задать вопрос 🔮".
 	case "question":
 		switch message.Text {
 		// Если нажата кнопка "Назад в меню", возвращаемся в главное меню.
-		case "Назад в меню":
-			userState[message.Chat.ID] = "main"
-			lastBotMessageID = sendMainMenu(bot, message.Chat.ID)
-		// Если введён любой другой текст, считаем его самостоятельным вопросом.
+		case backButtonLabel:
+			session.State = "main"
+			session.PendingSpread = ""
+			session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+		// Если нажата кнопка готового расклада, делаем расклад с его позициями и вопросом
+		// или, если расклад толкуется по вопросу пользователя, просим этот вопрос написать.
 		default:
-			if len(message.Text) > 200 {
-				// Игнорируем не-текстовые сообщения
-				// Отправляем сообщение, что сообщение длинное или не текстовое.
-				lastBotMessageID = sendMessage(bot, message.Chat.ID, "Вы отправили слишком длинное сообщение, либо сообщение не текстовое.")
+			if spread, ok := spreadBook.ByButton(message.Text); ok {
+				if spread.AskQuestion {
+					session.PendingSpread = spread.ID
+					session.rememberBotMessage(sendMessage(bot, message.Chat.ID, fmt.Sprintf("Расклад «%s». Напишите свой вопрос одним сообщением.", spread.Title)))
+				} else {
+					performReading(bot, message, &session, spread, spread.Question)
+				}
+			} else if message.Text == "/start" {
+				openMenu(bot, message.Chat.ID, messageUserID(message), &session, menuMain)
+			} else if message.Text == "" || strings.HasPrefix(message.Text, "/") {
+				// Фото, стикер, голосовое сообщение или команда — не вопрос: расклад не делаем и ничего не списываем.
+				session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Напишите вопрос текстом или выберите расклад из меню."))
+			} else if questionTooLong(message.Text) {
+				// Отправляем сообщение, что вопрос слишком длинный.
+				session.rememberBotMessage(sendMessage(bot, message.Chat.ID, fmt.Sprintf("Вопрос слишком длинный: уложитесь в %d символов.", maxQuestionLength)))
 				// После обработки вопроса возвращаем пользователя в главное меню.
-				userState[message.Chat.ID] = "main"
+				session.State = "main"
+				session.PendingSpread = ""
 			} else {
-				// Загружаем карты из JSON-файла
-				cards, err := loadTarotCards("tarocards.json")
-				if err != nil {
-					fmt.Println("Ошибка загрузки карт:", err) // Выводим ошибку, если файл не загрузился
-					return                                    // Завершаем выполнение программы
-				}
-
-				// Выбираем 3 случайные карты
-				selected := drawThreeCards(cards)
-
-				// Выводим результат в консоль
-				fmt.Println("Ваши карты:")
-				cardMsg, cardPrompt := "", ""
-				for _, card := range selected { // Итерируемся по выбранным картам
-					cardMsg = cardMsg + card.Name + "\n" + card.Description + "\n\n\n"
-					cardPrompt = cardPrompt + card.Name + "\n"
-				}
-
-				msg := tgbotapi.NewMessage(message.Chat.ID, cardMsg)
-				_, err = bot.Send(msg)
-				if err != nil {
-					log.Printf("Ошибка отправки сообщения: %v", err)
+				// Любой другой текст считаем вопросом: к выбранному ранее раскладу или самостоятельным.
+				spread, ok := spreadBook.ByID(session.PendingSpread)
+				if !ok {
+					spread = spreadBook.Default()
 				}
-
-				userPrompt := `
-				Ты профессиональная рускоязычная гадалка-таролог! Разбираешься во всех терминах тарологии, во всех картах Таро и их значениях! \n
-				Ответь на мой вопросс максимально подробно, опираясь на выпавшие мне карты. \n
-				Вопрос:
-				` + message.Text + `\n Карты которые мне выпали: \n` + cardMsg
-
-				log.Printf("Сообщение от пользователя: %s", message.Text)
-
-				// Отправляем запрос в DeepSeek
-				answer, err := queryDeepSeek(userPrompt)
-				if err != nil {
-					answer = "Ошибка при запросе к DeepSeek: " + err.Error()
-				}
-
-				// Проверяем, что ответ не пустой
-				if answer == "" {
-					answer = "Извините, я не смог обработать ваш запрос."
-				}
-
-				// Отправляем ответ пользователю.
-				lastBotMessageID = sendMessage(bot, message.Chat.ID, answer)
-				// После обработки вопроса возвращаем пользователя в главное меню.
-				userState[message.Chat.ID] = "main"
-
+				performReading(bot, message, &session, spread, message.Text)
 			}
 		}
 
+	// Состояние "reading" — пользователь получил расклад и может задать уточняющие вопросы.
+	case "reading":
+		handleReading(bot, message, &session)
+
+	// Состояние "encyclopedia" — пользователь просматривает справочник значений карт.
+	case "encyclopedia":
+		handleEncyclopedia(bot, message, &session)
+
 	// Состояния "instruction" и "tariffs" — пользователь просматривает информацию.
 	case "instruction", "tariffs":
 		// В этих режимах единственная допустимая команда — "Назад в меню".
-		if message.Text == "Назад в меню" {
+		if message.Text == backButtonLabel || message.Text == "/start" {
 			// Переключаем состояние в "main" и отправляем главное меню.
-			userState[message.Chat.ID] = "main"
-			lastBotMessageID = sendMainMenu(bot, message.Chat.ID)
+			session.State = "main"
+			session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
 		} else {
 			// Если вводится произвольный текст, выдаем сообщение об ошибке.
-			lastBotMessageID = sendMessage(bot, message.Chat.ID, "Неизвестная команда. Выберите пункт 'Назад в меню'.")
+			session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Неизвестная команда. Выберите пункт 'Назад в меню'."))
 		}
 
 	// Если по какой-то причине состояние не соответствует ни одному из вышеописанных, сбрасываем его в "main".
 	default:
-		userState[message.Chat.ID] = "main"
-		lastBotMessageID = sendMainMenu(bot, message.Chat.ID)
+		session.State = "main"
+		session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+	}
+}
+
+// Функция cleanupChat удаляет сообщение пользователя и сообщения предыдущего ответа бота.
+// Ошибки удаления только логируются: Telegram не даёт удалять сообщения старше 48 часов.
+func cleanupChat(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	// Создаем объект для удаления сообщения пользователя по ID чата и ID сообщения.
+	if _, err := bot.Request(tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)); err != nil {
+		log.Printf("Не удалось удалить сообщение пользователя %d: %v", message.MessageID, err)
+	}
+	// Удаляем сообщения, которые бот отправил в этот чат в прошлый раз.
+	deleteBotMessages(bot, message.Chat.ID, session)
+}
+
+// Функция deleteBotMessages удаляет сообщения последнего ответа бота в чате.
+func deleteBotMessages(bot *tgbotapi.BotAPI, chatID int64, session *Session) {
+	for _, messageID := range session.BotMessageIDs {
+		if _, err := bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+			log.Printf("Не удалось удалить сообщение бота %d: %v", messageID, err)
+		}
 	}
+	session.BotMessageIDs = nil
 }
 
 // Функция sendMessage отправляет текстовое сообщение с клавиатурой, содержащей кнопку "Назад в меню".
@@ -218,7 +468,7 @@ func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) int {
 	// Добавляем клавиатуру с единственной кнопкой "Назад в меню".
 	msg.ReplyMarkup = tgbotapi.ReplyKeyboardMarkup{
 		Keyboard: [][]tgbotapi.KeyboardButton{
-			{tgbotapi.NewKeyboardButton("Назад в меню")},
+			{tgbotapi.NewKeyboardButton(backButtonLabel)},
 		},
 		ResizeKeyboard:  true,  // Автоматическая адаптация размеров клавиатуры под устройство пользователя.
 		OneTimeKeyboard: false, // Клавиатура исчезает после нажатия на кнопку.
@@ -229,82 +479,31 @@ func sendMessage(bot *tgbotapi.BotAPI, chatID int64, text string) int {
 }
 
 // Функция sendMainMenu отправляет главное меню с кнопками для перехода в различные режимы.
-// Главное меню содержит кнопки: "🔮 Задать вопрос 🔮", "📑 Инструкция 📑", "💲Тарифы💲" и "Назад в меню".
+// Главное меню содержит кнопки: "🔮 Задать вопрос 🔮", "📚 Значения карт", "📑 Инструкция 📑" и "💲Тарифы💲".
 func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) int {
 	// Создаем сообщение с текстом главного меню.
 	msg := tgbotapi.NewMessage(chatID, "Выберите действие:")
-	// Определяем клавиатуру главного меню.
-	msg.ReplyMarkup = tgbotapi.ReplyKeyboardMarkup{
-		Keyboard: [][]tgbotapi.KeyboardButton{
-			// Первая строка: кнопка "🔮 Задать вопрос 🔮"
-			{tgbotapi.NewKeyboardButton("🔮 Задать вопрос 🔮")},
-			// Вторая строка: кнопка "📑 Инструкция 📑"
-			{tgbotapi.NewKeyboardButton("📑 Инструкция 📑")},
-			// Третья строка: кнопка "💲Тарифы💲"
-			{tgbotapi.NewKeyboardButton("💲Тарифы💲")},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: false,
-	}
+	// Определяем клавиатуру главного меню: по строке на каждый пункт из mainMenuItems.
+	msg.ReplyMarkup = menuKeyboard(mainMenuItems)
 	// Отправляем сообщение и сохраняем его ID.
 	sentMsg, _ := bot.Send(msg)
 	return sentMsg.MessageID
 }
 
 // Функция sendQuestionMenu отправляет меню для режима "🔮 Задать вопрос 🔮".
-// Здесь пользователь может выбрать один из вариантов (с заранее заданными кнопками)
+// Здесь пользователь может выбрать один из раскладов (кнопки берутся из spreads.json)
 // или ввести свой вопрос вручную (если текст не соответствует кнопкам).
 func sendQuestionMenu(bot *tgbotapi.BotAPI, chatID int64) int {
 	// Создаем сообщение с текстом меню вопросов.
 	msg := tgbotapi.NewMessage(chatID, "Выберите вопрос или введите его самостоятельно:")
-	// Определяем клавиатуру с вариантами и кнопкой "Назад в меню".
-	msg.ReplyMarkup = tgbotapi.ReplyKeyboardMarkup{
-		Keyboard: [][]tgbotapi.KeyboardButton{
-			// Первая строка: кнопка "Что ждёт меня сегодня?"
-			{tgbotapi.NewKeyboardButton("⏰ Что ждёт меня сегодня? ⏰")},
-			// Вторая строка: кнопка "💔 Любовный расклад 💔"
-			{tgbotapi.NewKeyboardButton("💔 Любовный расклад 💔")},
-			// Вторая строка: кнопка "👩🏻‍💼 Карьерный расклад 👩🏻‍💼"
-			{tgbotapi.NewKeyboardButton("👩🏻‍💼 Карьерный расклад 👩🏻‍💼")},
-			// Вторая строка: кнопка "💵 Финансовый расклад 💵"
-			{tgbotapi.NewKeyboardButton("💵 Финансовый расклад 💵")},
-			// Третья строка: кнопка "Назад в меню"
-			{tgbotapi.NewKeyboardButton("Назад в меню")},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: false,
-	}
-	// Отправляем сообщение и возвращаем его ID.
-	sentMsg, _ := bot.Send(msg)
-	return sentMsg.MessageID
-}
-
-// Функция sendInstruction отправляет сообщение с инструкцией и клавиатурой с кнопкой "Назад в меню".
-func sendInstruction(bot *tgbotapi.BotAPI, chatID int64) int {
-	// Создаем сообщение с текстом инструкции.
-	msg := tgbotapi.NewMessage(chatID, "📑 Инструкция 📑: \nЗдесь будет наша инструкция.")
-	// Определяем клавиатуру с кнопкой "Назад в меню".
-	msg.ReplyMarkup = tgbotapi.ReplyKeyboardMarkup{
-		Keyboard: [][]tgbotapi.KeyboardButton{
-			{tgbotapi.NewKeyboardButton("Назад в меню")},
-		},
-		ResizeKeyboard:  true,
-		OneTimeKeyboard: false,
+	// Определяем клавиатуру: по кнопке на каждый расклад из spreads.json и кнопка "Назад в меню".
+	var keyboard [][]tgbotapi.KeyboardButton
+	for _, button := range spreadBook.Buttons() {
+		keyboard = append(keyboard, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(button)))
 	}
-	// Отправляем сообщение и возвращаем его ID.
-	sentMsg, _ := bot.Send(msg)
-	return sentMsg.MessageID
-}
-
-// Функция sendTariffs отправляет сообщение с описанием тарифов и клавиатурой с кнопкой "Назад в меню".
-func sendTariffs(bot *tgbotapi.BotAPI, chatID int64) int {
-	// Создаем сообщение с текстом тарифов.
-	msg := tgbotapi.NewMessage(chatID, "💲Тарифы💲: \nЗдесь будет описание тарифов.")
-	// Определяем клавиатуру с кнопкой "Назад в меню".
+	keyboard = append(keyboard, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(backButtonLabel)))
 	msg.ReplyMarkup = tgbotapi.ReplyKeyboardMarkup{
-		Keyboard: [][]tgbotapi.KeyboardButton{
-			{tgbotapi.NewKeyboardButton("Назад в меню")},
-		},
+		Keyboard:        keyboard,
 		ResizeKeyboard:  true,
 		OneTimeKeyboard: false,
 	}
@@ -327,95 +526,69 @@ func importEnv(fileName, varName string) (variable string) {
 	return
 }
 
-// Структура запроса к DeepSeek API (Ollama)
-type DeepSeekRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-}
-
-// Структура ответа от DeepSeek API
-type DeepSeekResponse struct {
-	Choices []struct {
-		Text string `json:"text"`
-	} `json:"choices"`
+// Функция envOrDefault возвращает значение необязательной переменной среды
+// или значение по умолчанию, если переменная не задана.
+// Переменные из hiddenFiles.env к этому моменту уже загружены функцией importEnv.
+func envOrDefault(varName, defaultValue string) string {
+	if value := os.Getenv(varName); value != "" {
+		return value
+	}
+	return defaultValue
 }
 
-// Функция запроса к DeepSeek API
-func queryDeepSeek(prompt string) (string, error) {
-	url := "http://localhost:11434/v1/completions"
-
-	// Формируем JSON-запрос
-	reqBody, err := json.Marshal(map[string]interface{}{
-		"model":  "deepseek-r1:32b",
-		"prompt": prompt,
-	})
-	if err != nil {
-		return "", fmt.Errorf("ошибка формирования запроса: %v", err)
+// Функция envInt возвращает целочисленную переменную среды или значение по умолчанию.
+func envInt(varName string, defaultValue int) (int, error) {
+	value := os.Getenv(varName)
+	if value == "" {
+		return defaultValue, nil
 	}
-
-	// Отправляем HTTP-запрос
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(reqBody))
+	n, err := strconv.Atoi(value)
 	if err != nil {
-		return "", fmt.Errorf("ошибка отправки запроса: %v", err)
+		return 0, fmt.Errorf("некорректное значение %s: %v", varName, err)
 	}
-	defer resp.Body.Close()
+	return n, nil
+}
 
-	// Декодируем JSON-ответ
-	var dsResp DeepSeekResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dsResp); err != nil {
-		return "", fmt.Errorf("ошибка декодирования JSON: %v", err)
+// Функция envDuration возвращает переменную среды с длительностью (например, "90s") или значение по умолчанию.
+func envDuration(varName string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(varName)
+	if value == "" {
+		return defaultValue, nil
 	}
-
-	// Проверяем, есть ли текст в `choices`
-	if len(dsResp.Choices) == 0 {
-		return "DeepSeek не вернул текстовый ответ.", nil
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("некорректное значение %s: %v", varName, err)
 	}
-
-	// Убираем <think> ... </think> (если есть)
-	responseText := regexp.MustCompile(`(?s)<think>.*?</think>`).ReplaceAllString(dsResp.Choices[0].Text, "")
-	responseText = strings.TrimSpace(responseText)
-
-	// Возвращаем обработанный ответ
-	return responseText, nil
+	return d, nil
 }
 
-// Определяем структуру, которая будет представлять карту Таро
-// Name - название карты, Description - её значение (около 300 символов)
-type TarotCard struct {
-	Name        string `json:"name"`        // Название карты, соответствует ключу "name" в JSON
-	Description string `json:"description"` // Описание карты, соответствует ключу "description" в JSON
+// Функция newSessionStore создаёт хранилище сессий согласно настройкам:
+// SESSION_STORE=memory — только в памяти, SESSION_STORE=file (по умолчанию) — JSON-файл SESSION_FILE.
+func newSessionStore() (SessionStore, error) {
+	switch kind := envOrDefault("SESSION_STORE", "file"); kind {
+	case "memory":
+		return NewMemorySessionStore(), nil
+	case "file":
+		return NewFileSessionStore(envOrDefault("SESSION_FILE", "data/sessions.json"))
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища сессий: %s", kind)
+	}
 }
 
-// Функция загрузки карт из JSON-файла
-func loadTarotCards(filename string) ([]TarotCard, error) {
-	// Читаем содержимое файла
-	file, err := os.ReadFile(filename)
+// Функция newHistoryStore создаёт хранилище истории раскладов согласно настройкам:
+// HISTORY_STORE=memory — только в памяти, HISTORY_STORE=file (по умолчанию) — JSON-файл HISTORY_FILE.
+// HISTORY_LIMIT — сколько последних раскладов хранится у каждого пользователя.
+func newHistoryStore() (HistoryStore, error) {
+	limit, err := envInt("HISTORY_LIMIT", 50)
 	if err != nil {
-		return nil, err // В случае ошибки возвращаем nil и ошибку
+		return nil, err
 	}
-
-	// Создаём слайс для хранения карт
-	var cards []TarotCard
-
-	// Разбираем JSON в слайс структур TarotCard
-	err = json.Unmarshal(file, &cards)
-	if err != nil {
-		return nil, err // Если возникла ошибка при разборе, возвращаем nil и ошибку
+	switch kind := envOrDefault("HISTORY_STORE", "file"); kind {
+	case "memory":
+		return NewMemoryHistoryStore(limit), nil
+	case "file":
+		return NewFileHistoryStore(envOrDefault("HISTORY_FILE", "data/history.json"), limit)
+	default:
+		return nil, fmt.Errorf("неизвестный тип хранилища истории: %s", kind)
 	}
-
-	return cards, nil // Возвращаем загруженные карты
-}
-
-// Функция выбора 3 случайных карт
-func drawThreeCards(cards []TarotCard) []TarotCard {
-	// Устанавливаем seed (инициализируем генератор случайных чисел)
-	rand.Seed(time.Now().UnixNano()) // Используем текущее время в наносекундах, чтобы каждый раз был разный результат
-
-	// Перемешиваем слайс карт случайным образом
-	rand.Shuffle(len(cards), func(i, j int) {
-		cards[i], cards[j] = cards[j], cards[i] // Меняем местами элементы i и j
-	})
-
-	// Возвращаем первые три карты из перемешанного списка
-	return cards[:3]
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Функция setupQuestionChat подставляет расклады и хранилище сессий в памяти
+// и переводит чат userID в режим ввода вопроса.
+func setupQuestionChat(t *testing.T, userID int64) {
+	t.Helper()
+	setupPayments(t)
+	oldSessions, oldBook := sessions, spreadBook
+	t.Cleanup(func() { sessions, spreadBook = oldSessions, oldBook })
+
+	book, err := loadSpreads("spreads.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spreadBook = book
+	sessions = NewMemorySessionStore()
+	sessions.Set(userID, Session{State: "question"})
+}
+
+// Фото, стикер или команда в режиме вопроса — не вопрос: расклад не делается и ничего не списывается.
+func TestQuestionStateRejectsNonQuestions(t *testing.T) {
+	const userID = 42
+	tests := []struct {
+		name      string
+		text      string
+		wantState string
+	}{
+		{"фото без подписи", "", "question"},
+		{"неизвестная команда", "/help", "question"},
+		{"команда /start", "/start", "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupQuestionChat(t, userID)
+			bot, fake := newFakeBot(t)
+
+			handleMessage(bot, &tgbotapi.Message{
+				MessageID: 1,
+				From:      &tgbotapi.User{ID: userID},
+				Chat:      &tgbotapi.Chat{ID: userID},
+				Text:      tt.text,
+			})
+
+			session, _ := sessions.Get(userID)
+			if session.State != tt.wantState {
+				t.Errorf("State = %q, ожидалось %q", session.State, tt.wantState)
+			}
+			if left := ledger.Account(userID).FreeLeft(time.Now(), tariffs.FreeDaily); left != tariffs.FreeDaily {
+				t.Errorf("списан бесплатный расклад: осталось %d из %d", left, tariffs.FreeDaily)
+			}
+			if len(fake.calls("sendMessage")) != 1 {
+				t.Errorf("ожидалось одно сообщение, отправлено %d", len(fake.calls("sendMessage")))
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// backButtonLabel — подпись кнопки возврата в главное меню на reply-клавиатурах.
+const backButtonLabel = "Назад в меню"
+
+// Действия главного меню. Одни и те же действия вызываются кнопками reply-клавиатуры
+// (по подписи из mainMenuItems) и inline-кнопками "menu:<действие>".
+const (
+	menuMain    = "main"    // Главное меню
+	menuAsk     = "ask"     // Меню вопросов и раскладов
+	menuCards   = "cards"   // Справочник значений карт
+	menuGuide   = "guide"   // Инструкция
+	menuTariffs = "tariffs" // Тарифы и баланс
+)
+
+// menuItem — пункт меню на reply-клавиатуре.
+type menuItem struct {
+	Action string
+	Label  string
+}
+
+// mainMenuItems — пункты главного меню в порядке кнопок.
+// Подписи можно менять только здесь: обработчик сообщений находит действие по этой таблице.
+var mainMenuItems = []menuItem{
+	{menuAsk, "🔮 Задать вопрос 🔮"},
+	{menuCards, "📚 Значения карт"},
+	{menuGuide, "📑 Инструкция 📑"},
+	{menuTariffs, "💲Тарифы💲"},
+}
+
+// Функция menuActionByLabel находит действие меню items по подписи нажатой кнопки.
+// Кнопка "Назад в меню" есть на всех клавиатурах и всегда означает menuMain.
+func menuActionByLabel(items []menuItem, label string) (string, bool) {
+	if label == backButtonLabel {
+		return menuMain, true
+	}
+	for _, item := range items {
+		if item.Label == label {
+			return item.Action, true
+		}
+	}
+	return "", false
+}
+
+// Функция menuKeyboard строит reply-клавиатуру с пунктами items по одному в строке.
+func menuKeyboard(items []menuItem) tgbotapi.ReplyKeyboardMarkup {
+	var keyboard [][]tgbotapi.KeyboardButton
+	for _, item := range items {
+		keyboard = append(keyboard, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(item.Label)))
+	}
+	return tgbotapi.ReplyKeyboardMarkup{
+		Keyboard:        keyboard,
+		ResizeKeyboard:  true,
+		OneTimeKeyboard: false,
+	}
+}
+
+// Функция menuButton создаёт inline-кнопку возврата в главное меню.
+func menuButton() tgbotapi.InlineKeyboardButton {
+	return callbackButton("🏠 Меню", newCallbackData(callbackMenu, menuMain))
+}
+
+// Функция openMenu переводит чат в режим action и отправляет его экран.
+// Неизвестное действие открывает главное меню.
+func openMenu(bot *tgbotapi.BotAPI, chatID, userID int64, session *Session, action string) {
+	var messageIDs []int
+	switch action {
+	case menuAsk:
+		session.State = "question"
+		messageIDs = []int{sendQuestionMenu(bot, chatID)}
+	case menuCards:
+		session.State = "encyclopedia"
+		messageIDs = sendEncyclopedia(bot, chatID)
+	case menuGuide:
+		session.State = "instruction"
+		messageIDs = sendInstruction(bot, chatID)
+	case menuTariffs:
+		session.State = "tariffs"
+		messageIDs = sendTariffs(bot, chatID, userID)
+	default:
+		session.State = "main"
+		messageIDs = []int{sendMainMenu(bot, chatID)}
+	}
+	// Уточняющие вопросы задаются только в состоянии "reading", поэтому расклад закрывается,
+	// а выбранный, но не начатый расклад забывается
+	session.Reading = nil
+	session.PendingSpread = ""
+	for _, messageID := range messageIDs {
+		session.rememberBotMessage(messageID)
+	}
+}
+
+// Функция handleMenuCallback обрабатывает inline-кнопки "menu:<действие>": открывает режим новым сообщением.
+// В режиме чистого чата предыдущий ответ бота, включая сообщение с нажатой кнопкой, удаляется.
+func handleMenuCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string {
+	switch data.Action {
+	case menuMain, menuAsk, menuCards, menuGuide, menuTariffs:
+	default:
+		return unknownCallback(query)
+	}
+	chatID := query.Message.Chat.ID
+	if cleanChat {
+		deleteBotMessages(bot, chatID, session)
+	}
+	openMenu(bot, chatID, query.From.ID, session, data.Action)
+	return ""
+}
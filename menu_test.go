@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+// Каждая кнопка reply-клавиатуры должна находить своё действие по подписи.
+func TestMenuActionByLabel(t *testing.T) {
+	for name, items := range map[string][]menuItem{"главное меню": mainMenuItems, "меню расклада": readingMenuItems} {
+		for _, row := range menuKeyboard(items).Keyboard {
+			label := row[0].Text
+			action, ok := menuActionByLabel(items, label)
+			if !ok {
+				t.Errorf("%s: кнопка %q не распознаётся", name, label)
+				continue
+			}
+			if label == backButtonLabel && action != menuMain {
+				t.Errorf("%s: %q ведёт к %q, а не в главное меню", name, label, action)
+			}
+		}
+		if _, ok := menuActionByLabel(items, "произвольный вопрос"); ok {
+			t.Errorf("%s: произвольный текст распознан как кнопка", name)
+		}
+	}
+}
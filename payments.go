@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Глобальный каталог тарифов, загружается из tariffs.json при запуске.
+var tariffs *TariffCatalog
+
+// Глобальный баланс пользователей: бесплатные, купленные расклады и подписки.
+var ledger *Ledger
+
+// Глобальные ограничения частоты запросов к модели по уровням тарифов.
+var quotas *Quotas
+
+// Глобальный токен платёжного провайдера (переменная PAYMENT_PROVIDER_TOKEN).
+// Если он не задан, тарифы нельзя купить за рубли.
+var paymentProviderToken string
+
+// Глобальный флаг оплаты в Telegram Stars (переменная STARS_PAYMENTS, по умолчанию включена).
+// Для звёзд платёжный провайдер не нужен.
+var starsPayments bool
+
+// Функция sendTariffs показывает каталог тарифов и баланс пользователя:
+// подсказку с клавиатурой "Назад в меню" и сообщение с inline-кнопками покупки за рубли и за звёзды.
+// Возвращает ID отправленных сообщений.
+func sendTariffs(bot *tgbotapi.BotAPI, chatID, userID int64) []int {
+	hintID := sendMessage(bot, chatID, "💲Тарифы💲\n"+balanceText(userID, time.Now()))
+
+	var b strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, tariff := range tariffs.Tariffs {
+		var prices []string
+		var row []tgbotapi.InlineKeyboardButton
+		if tariff.Price > 0 {
+			prices = append(prices, tariffs.FormatPrice(tariff.Price))
+			if paymentProviderToken != "" {
+				label := fmt.Sprintf("💳 %s — %s", tariff.Title, tariffs.FormatPrice(tariff.Price))
+				row = append(row, callbackButton(label, newCallbackData(callbackTariff, "buy", tariff.ID)))
+			}
+		}
+		if tariff.Stars > 0 {
+			prices = append(prices, fmt.Sprintf("%d ⭐", tariff.Stars))
+			if starsPayments {
+				label := fmt.Sprintf("⭐ %s — %d", tariff.Title, tariff.Stars)
+				row = append(row, callbackButton(label, newCallbackData(callbackTariff, "stars", tariff.ID)))
+			}
+		}
+		fmt.Fprintf(&b, "• %s — %s\n%s\n\n", tariff.Title, strings.Join(prices, " или "), tariff.Description)
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, strings.TrimSpace(b.String()))
+	if len(rows) == 0 {
+		msg.Text += "\n\nОплата пока не подключена."
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(menuButton()))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sentMsg, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Ошибка отправки тарифов: %v", err)
+	}
+	return []int{hintID, sentMsg.MessageID}
+}
+
+// Функция balanceText описывает баланс пользователя: подписку, бесплатные и купленные расклады.
+func balanceText(userID int64, now time.Time) string {
+	account := ledger.Account(userID)
+	var lines []string
+	if account.Subscribed(now) {
+		lines = append(lines, "Подписка действует до "+account.SubscriptionUntil.Format("02.01.2006 15:04")+".")
+	}
+	if tariffs.FreeDaily > 0 {
+		lines = append(lines, fmt.Sprintf("Бесплатных раскладов сегодня: %d из %d.", account.FreeLeft(now, tariffs.FreeDaily), tariffs.FreeDaily))
+	}
+	if account.Readings > 0 {
+		lines = append(lines, fmt.Sprintf("Оплаченных раскладов: %d.", account.Readings))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Функция checkReadingAllowed проверяет перед раскладом дневную норму, баланс и частоту запросов пользователя.
+// Если раскладов не осталось, пользователь получает каталог тарифов, а чат переходит в состояние "tariffs";
+// если запросы слишком частые — сообщение о том, через сколько можно повторить.
+func checkReadingAllowed(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) bool {
+	err := quotas.AcquireReading(messageUserID(message), time.Now())
+	if err == nil {
+		return true
+	}
+	var denied *QuotaDenied
+	if !errors.As(err, &denied) {
+		// Не удалось сохранить состояние ограничений — расклад всё равно делаем
+		log.Printf("Ошибка проверки ограничений пользователя %d: %v", messageUserID(message), err)
+		return true
+	}
+
+	if denied.Reason == QuotaRate {
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID,
+			fmt.Sprintf("⏳ Карты нужно перетасовать. Следующий расклад можно сделать через %s.", formatWait(denied.RetryAfter))))
+		session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+		return false
+	}
+	text := "🔮 Расклады на балансе закончились. Чтобы продолжить, выберите тариф."
+	if denied.RetryAfter > 0 {
+		text = fmt.Sprintf("🔮 Бесплатные расклады на сегодня закончились. Новые появятся через %s, или выберите тариф.", formatWait(denied.RetryAfter))
+	}
+	session.rememberBotMessage(sendMessage(bot, message.Chat.ID, text))
+	session.State = "tariffs"
+	for _, messageID := range sendTariffs(bot, message.Chat.ID, messageUserID(message)) {
+		session.rememberBotMessage(messageID)
+	}
+	return false
+}
+
+// Функция checkFollowUpAllowed проверяет частоту уточняющих вопросов пользователя.
+// Если вопросы слишком частые, пользователь узнаёт, через сколько можно спросить снова.
+func checkFollowUpAllowed(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) bool {
+	err := quotas.AcquireFollowUp(messageUserID(message), time.Now())
+	var denied *QuotaDenied
+	if !errors.As(err, &denied) {
+		if err != nil {
+			log.Printf("Ошибка проверки ограничений пользователя %d: %v", messageUserID(message), err)
+		}
+		return true
+	}
+	session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID,
+		fmt.Sprintf("⏳ Слишком много вопросов подряд. Следующий можно задать через %s.", formatWait(denied.RetryAfter))))
+	return false
+}
+
+// Функция chargeReading списывает сделанный расклад с баланса отправителя сообщения.
+// Вызывается только после успешного толкования: за несостоявшийся расклад плата не берётся.
+func chargeReading(message *tgbotapi.Message) {
+	userID := messageUserID(message)
+	source, err := ledger.Consume(userID, time.Now())
+	if err != nil {
+		log.Printf("Ошибка списания расклада пользователя %d: %v", userID, err)
+		return
+	}
+	log.Printf("Пользователь %d: расклад списан (%s)", userID, source)
+}
+
+// Функция handleTariffCallback обрабатывает inline-кнопки покупки: "tariff:buy:<ID тарифа>" —
+// счёт в валюте каталога через платёжного провайдера, "tariff:stars:<ID тарифа>" — счёт в Telegram Stars.
+func handleTariffCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, session *Session, data CallbackData) string {
+	var currency, providerToken string
+	switch data.Action {
+	case "buy":
+		if paymentProviderToken == "" {
+			return "Оплата картой сейчас недоступна."
+		}
+		currency, providerToken = tariffs.Currency, paymentProviderToken
+	case "stars":
+		if !starsPayments {
+			return "Оплата звёздами сейчас недоступна."
+		}
+		// Для оплаты звёздами токен провайдера передаётся пустым
+		currency = starsCurrency
+	default:
+		return unknownCallback(query)
+	}
+	tariff, ok := tariffs.ByID(data.Arg(0))
+	if !ok {
+		return "Этот тариф больше не продаётся."
+	}
+	price, ok := tariffs.PriceIn(tariff, currency)
+	if !ok {
+		return "Этот тариф нельзя оплатить таким способом."
+	}
+
+	invoice := tgbotapi.NewInvoice(query.Message.Chat.ID, tariff.Title, tariff.Description,
+		invoicePayload(tariff), providerToken, "", currency,
+		[]tgbotapi.LabeledPrice{{Label: tariff.Title, Amount: price}})
+	sentMsg, err := bot.Send(invoice)
+	if err != nil {
+		log.Printf("Ошибка отправки счёта за тариф %s (%s): %v", tariff.ID, currency, err)
+		return "Не удалось выставить счёт, попробуйте позже."
+	}
+	session.rememberBotMessage(sentMsg.MessageID)
+	return ""
+}
+
+// Функция invoicePayload строит служебные данные счёта, по которым потом определяется купленный тариф.
+func invoicePayload(tariff Tariff) string {
+	return "tariff:" + tariff.ID
+}
+
+// Функция tariffFromPayload находит тариф по служебным данным счёта.
+func tariffFromPayload(payload string) (Tariff, bool) {
+	id, ok := strings.CutPrefix(payload, "tariff:")
+	if !ok {
+		return Tariff{}, false
+	}
+	return tariffs.ByID(id)
+}
+
+// Функция tariffPriceMatches проверяет, что сумма платежа совпадает с ценой тарифа в валюте currency.
+func tariffPriceMatches(tariff Tariff, currency string, amount int) bool {
+	price, ok := tariffs.PriceIn(tariff, currency)
+	return ok && price == amount
+}
+
+// Функция handlePreCheckout подтверждает или отклоняет платёж перед списанием денег:
+// тариф должен существовать, а сумма — совпадать с ценой в каталоге в той же валюте (рубли или звёзды).
+func handlePreCheckout(bot *tgbotapi.BotAPI, query *tgbotapi.PreCheckoutQuery) {
+	answer := tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, OK: true}
+	tariff, ok := tariffFromPayload(query.InvoicePayload)
+	switch {
+	case !ok:
+		answer.OK, answer.ErrorMessage = false, "Этот тариф больше не продаётся. Откройте список тарифов заново."
+	case !tariffPriceMatches(tariff, query.Currency, query.TotalAmount):
+		answer.OK, answer.ErrorMessage = false, "Цена тарифа изменилась. Откройте список тарифов заново."
+	}
+	if !answer.OK {
+		log.Printf("Платёж отклонён (%s, %d %s): %s", query.InvoicePayload, query.TotalAmount, query.Currency, answer.ErrorMessage)
+	}
+	if _, err := bot.Request(answer); err != nil {
+		log.Printf("Ошибка ответа на pre_checkout_query: %v", err)
+	}
+}
+
+// Функция handleSuccessfulPayment зачисляет оплаченный тариф на баланс пользователя.
+func handleSuccessfulPayment(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	payment := message.SuccessfulPayment
+	tariff, ok := tariffFromPayload(payment.InvoicePayload)
+	if !ok || message.From == nil {
+		// Деньги уже списаны, поэтому такой платёж нужно разобрать вручную
+		log.Printf("Платёж %s с неизвестным тарифом %q: %d %s", payment.TelegramPaymentChargeID, payment.InvoicePayload, payment.TotalAmount, payment.Currency)
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Оплата получена, но тариф не найден. Мы разберёмся и свяжемся с вами."))
+		return
+	}
+
+	err := ledger.Credit(message.From.ID, tariff, Payment{
+		ChargeID:         payment.TelegramPaymentChargeID,
+		ProviderChargeID: payment.ProviderPaymentChargeID,
+		TariffID:         tariff.ID,
+		Amount:           payment.TotalAmount,
+		Currency:         payment.Currency,
+		Time:             time.Now(),
+	})
+	if err != nil {
+		// Деньги уже списаны, а баланс не изменился: платёж нужно зачислить вручную по charge ID
+		log.Printf("Ошибка зачисления платежа %s (тариф %s, %d %s) пользователю %d, зачислите вручную: %v",
+			payment.TelegramPaymentChargeID, tariff.ID, payment.TotalAmount, payment.Currency, message.From.ID, err)
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID,
+			fmt.Sprintf("Оплата получена, но тариф «%s» зачислится с задержкой. Мы разберёмся и зачислим его вручную.", tariff.Title)))
+		return
+	}
+	log.Printf("Пользователь %d оплатил тариф %s (%d %s)", message.From.ID, tariff.ID, payment.TotalAmount, payment.Currency)
+
+	session.State = "main"
+	session.rememberBotMessage(sendMessage(bot, message.Chat.ID,
+		fmt.Sprintf("✅ Спасибо! Тариф «%s» оплачен.\n%s", tariff.Title, balanceText(message.From.ID, time.Now()))))
+	session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeBotAPI — тестовый сервер Bot API: запоминает вызванные методы с параметрами
+// и отвечает успехом, если метод не перечислен в failing.
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	requests []fakeRequest
+	failing  map[string]string // Метод → описание ошибки, которую вернёт сервер
+}
+
+type fakeRequest struct {
+	Method string
+	Form   url.Values
+}
+
+// Функция newFakeBot запускает тестовый сервер Bot API и создаёт подключённого к нему бота.
+func newFakeBot(t *testing.T) (*tgbotapi.BotAPI, *fakeBotAPI) {
+	t.Helper()
+	fake := &fakeBotAPI{failing: make(map[string]string)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("TOKEN", srv.URL+"/bot%s/%s", srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.reset()
+	return bot, fake
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Фото загружаются multipart-формой, остальные запросы — обычной; ParseMultipartForm разбирает обе
+	r.ParseMultipartForm(1 << 20)
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{Method: method, Form: r.Form})
+	description, fail := f.failing[method]
+	f.mu.Unlock()
+
+	var result string
+	switch method {
+	case "getMe":
+		result = `{"id":1,"is_bot":true,"username":"test_bot"}`
+	case "sendMessage", "sendInvoice", "sendPhoto", "editMessageText":
+		result = `{"message_id":100,"chat":{"id":` + r.Form.Get("chat_id") + `}}`
+	default:
+		result = "true"
+	}
+	if fail {
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "error_code": 400, "description": description})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": json.RawMessage(result)})
+}
+
+// reset забывает уже вызванные методы.
+func (f *fakeBotAPI) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = nil
+}
+
+// calls возвращает вызовы метода method.
+func (f *fakeBotAPI) calls(method string) []url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result []url.Values
+	for _, r := range f.requests {
+		if r.Method == method {
+			result = append(result, r.Form)
+		}
+	}
+	return result
+}
+
+// Функция setupPayments подставляет каталог тарифов из tariffs.json и пустой баланс в памяти.
+func setupPayments(t *testing.T) {
+	t.Helper()
+	oldTariffs, oldLedger, oldToken, oldStars := tariffs, ledger, paymentProviderToken, starsPayments
+	t.Cleanup(func() {
+		tariffs, ledger, paymentProviderToken, starsPayments = oldTariffs, oldLedger, oldToken, oldStars
+	})
+
+	catalog, err := loadTariffs("tariffs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tariffs = catalog
+	ledger, _ = NewLedger("", catalog.FreeDaily)
+	paymentProviderToken, starsPayments = "", true
+}
+
+// Функция testCallbackQuery создаёт нажатие inline-кнопки с данными data в чате пользователя userID.
+func testCallbackQuery(userID int64, data string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "query",
+		From:    &tgbotapi.User{ID: userID},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: userID}},
+		Data:    data,
+	}
+}
+
+func TestTariffCallbackSendsInvoice(t *testing.T) {
+	setupPayments(t)
+	bot, fake := newFakeBot(t)
+	pack, _ := tariffs.ByID("pack5")
+
+	var session Session
+	query := testCallbackQuery(42, "tariff:stars:pack5")
+	data, _ := parseCallbackData(query.Data)
+	if answer := handleTariffCallback(bot, query, &session, data); answer != "" {
+		t.Fatalf("неожиданный ответ на нажатие: %q", answer)
+	}
+	invoices := fake.calls("sendInvoice")
+	if len(invoices) != 1 {
+		t.Fatalf("отправлено счетов: %d", len(invoices))
+	}
+	invoice := invoices[0]
+	if invoice.Get("currency") != starsCurrency || invoice.Get("provider_token") != "" || invoice.Get("payload") != invoicePayload(pack) {
+		t.Fatalf("неверный счёт в звёздах: %v", invoice)
+	}
+	var prices []tgbotapi.LabeledPrice
+	if err := json.Unmarshal([]byte(invoice.Get("prices")), &prices); err != nil || len(prices) != 1 || prices[0].Amount != pack.Stars {
+		t.Fatalf("неверная цена счёта: %s", invoice.Get("prices"))
+	}
+
+	// Оплата картой без токена провайдера отключена: счёт не выставляется
+	fake.reset()
+	data, _ = parseCallbackData("tariff:buy:pack5")
+	if answer := handleTariffCallback(bot, testCallbackQuery(42, data.String()), &session, data); answer == "" {
+		t.Fatal("ожидалось уведомление о недоступной оплате картой")
+	}
+	if len(fake.calls("sendInvoice")) != 0 {
+		t.Fatal("счёт не должен выставляться без токена провайдера")
+	}
+}
+
+func TestPreCheckout(t *testing.T) {
+	setupPayments(t)
+	bot, fake := newFakeBot(t)
+	pack, _ := tariffs.ByID("pack5")
+
+	tests := []struct {
+		name     string
+		payload  string
+		currency string
+		amount   int
+		wantOK   bool
+	}{
+		{"верная цена в звёздах", invoicePayload(pack), starsCurrency, pack.Stars, true},
+		{"верная цена в рублях", invoicePayload(pack), tariffs.Currency, pack.Price, true},
+		{"цена изменилась", invoicePayload(pack), starsCurrency, pack.Stars - 1, false},
+		{"неизвестный тариф", "tariff:missing", starsCurrency, pack.Stars, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake.reset()
+			handlePreCheckout(bot, &tgbotapi.PreCheckoutQuery{
+				ID: "pcq", From: &tgbotapi.User{ID: 42},
+				Currency: tt.currency, TotalAmount: tt.amount, InvoicePayload: tt.payload,
+			})
+			answers := fake.calls("answerPreCheckoutQuery")
+			if len(answers) != 1 {
+				t.Fatalf("ответов на pre_checkout_query: %d", len(answers))
+			}
+			if got := answers[0].Get("ok") == "true"; got != tt.wantOK {
+				t.Fatalf("ok = %v, ожидалось %v (%v)", got, tt.wantOK, answers[0])
+			}
+			if !tt.wantOK && answers[0].Get("error_message") == "" {
+				t.Fatal("при отказе нужно объяснение для пользователя")
+			}
+		})
+	}
+}
+
+// Telegram может прислать successful_payment дважды: тариф зачисляется один раз.
+func TestSuccessfulPaymentCreditedOnce(t *testing.T) {
+	setupPayments(t)
+	bot, fake := newFakeBot(t)
+	pack, _ := tariffs.ByID("pack5")
+
+	message := &tgbotapi.Message{
+		MessageID: 5,
+		From:      &tgbotapi.User{ID: 42},
+		Chat:      &tgbotapi.Chat{ID: 42},
+		SuccessfulPayment: &tgbotapi.SuccessfulPayment{
+			Currency: starsCurrency, TotalAmount: pack.Stars, InvoicePayload: invoicePayload(pack),
+			TelegramPaymentChargeID: "charge-1",
+		},
+	}
+	for i := 0; i < 2; i++ {
+		session := Session{State: "tariffs"}
+		handleSuccessfulPayment(bot, message, &session)
+		if session.State != "main" {
+			t.Fatalf("после оплаты состояние %q, ожидалось main", session.State)
+		}
+	}
+	account := ledger.Account(42)
+	if account.Readings != pack.Readings || len(account.Payments) != 1 {
+		t.Fatalf("баланс после двух уведомлений об одном платеже: %+v", account)
+	}
+	if len(fake.calls("sendMessage")) == 0 {
+		t.Fatal("пользователь не получил подтверждение оплаты")
+	}
+}
+
+func TestRefundStarPayment(t *testing.T) {
+	setupPayments(t)
+	bot, fake := newFakeBot(t)
+	pack, _ := tariffs.ByID("pack5")
+	ledger.Credit(42, pack, Payment{ChargeID: "charge-1", TariffID: pack.ID, Amount: pack.Stars, Currency: starsCurrency})
+	ledger.Credit(42, pack, Payment{ChargeID: "charge-rub", TariffID: pack.ID, Amount: pack.Price, Currency: tariffs.Currency})
+
+	// Telegram отклонил возврат: баланс не меняется
+	fake.failing["refundStarPayment"] = "Bad Request: CHARGE_NOT_FOUND"
+	refundPayment(bot, "charge-1")
+	if account := ledger.Account(42); account.Readings != 2*pack.Readings || account.Payments[0].Refunded {
+		t.Fatalf("неудачный возврат изменил баланс: %+v", account)
+	}
+
+	delete(fake.failing, "refundStarPayment")
+	fake.reset()
+	refundPayment(bot, "charge-1")
+	refunds := fake.calls("refundStarPayment")
+	if len(refunds) != 1 || refunds[0].Get("user_id") != "42" || refunds[0].Get("telegram_payment_charge_id") != "charge-1" {
+		t.Fatalf("неверный запрос refundStarPayment: %v", refunds)
+	}
+	if account := ledger.Account(42); account.Readings != pack.Readings || !account.Payments[0].Refunded {
+		t.Fatalf("после возврата: %+v", account)
+	}
+
+	// Повторный возврат и возврат рублёвого платежа в Telegram не отправляются
+	fake.reset()
+	refundPayment(bot, "charge-1")
+	refundPayment(bot, "charge-rub")
+	if calls := fake.calls("refundStarPayment"); len(calls) != 0 {
+		t.Fatalf("лишние запросы refundStarPayment: %v", calls)
+	}
+}
+
+// Без бесплатной нормы пользователь не получает обещание новых раскладов в полночь.
+func TestReadingDeniedMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		freeDaily int
+		want      string
+		dontWant  string
+	}{
+		{"бесплатные закончились", 1, "Новые появятся через", ""},
+		{"бесплатных нет", 0, "выберите тариф", "появятся"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupPayments(t)
+			oldQuotas := quotas
+			t.Cleanup(func() { quotas = oldQuotas })
+			tariffs.FreeDaily = tt.freeDaily
+			ledger, _ = NewLedger("", tt.freeDaily)
+			quotas, _ = NewQuotas("", ledger, nil)
+			ledger.Consume(1, time.Now())
+
+			bot, fake := newFakeBot(t)
+			var session Session
+			message := &tgbotapi.Message{From: &tgbotapi.User{ID: 1}, Chat: &tgbotapi.Chat{ID: 1}}
+			if checkReadingAllowed(bot, message, &session) {
+				t.Fatal("расклад без баланса не должен быть разрешён")
+			}
+			text := fake.calls("sendMessage")[0].Get("text")
+			if !strings.Contains(text, tt.want) || tt.dontWant != "" && strings.Contains(text, tt.dontWant) {
+				t.Errorf("сообщение %q", text)
+			}
+		})
+	}
+}
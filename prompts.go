@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// defaultPromptTemplate — шаблон запроса для раскладов, у которых шаблон не указан.
+const defaultPromptTemplate = "reading"
+
+// systemPromptTemplate — шаблон системного запроса с образом толкователя.
+const systemPromptTemplate = "system"
+
+// followUpPromptTemplate — шаблон уточняющего вопроса к уже сделанному раскладу.
+const followUpPromptTemplate = "followup"
+
+// promptFileName — имя файла шаблона: <название>.v<версия>.tmpl, например reading.v2.tmpl.
+var promptFileName = regexp.MustCompile(`^([a-z0-9_]+)\.v([0-9]+)\.tmpl$`)
+
+// PromptCard — карта расклада в виде, удобном для шаблона.
+type PromptCard struct {
+	Position            string // Название позиции
+	PositionDescription string // Что означает позиция
+	Name                string // Название карты
+	Orientation         string // "прямое положение" или "перевёрнутое положение"
+	Reversed            bool
+	Meaning             string // Значение карты с учётом положения
+}
+
+// PromptData — переменные, доступные в шаблонах запросов.
+type PromptData struct {
+	Question    string       // Вопрос пользователя
+	Spread      Spread       // Расклад (.Spread.Title, .Spread.ID, ...)
+	Instruction string       // Инструкция расклада из spreads.json
+	Cards       []PromptCard // Карты по позициям
+	Language    string       // Язык ответа, например "русский"
+	Persona     string       // Образ толкователя, например "профессиональная русскоязычная гадалка-таролог"
+}
+
+// Функция newPromptData собирает переменные шаблона по раскладу и вытянутым картам.
+func newPromptData(spread Spread, question string, cards []DrawnCard, languageCode string) PromptData {
+	data := PromptData{
+		Question:    question,
+		Spread:      spread,
+		Instruction: spread.Instruction,
+		Language:    languageName(languageCode),
+		Persona:     promptPersona,
+	}
+	for i, card := range cards {
+		pc := PromptCard{
+			Name:        card.Card.Name,
+			Orientation: card.Orientation(),
+			Reversed:    card.Reversed,
+			Meaning:     card.Meaning(),
+		}
+		if i < len(spread.Positions) {
+			pc.Position = spread.Positions[i].Name
+			pc.PositionDescription = spread.Positions[i].Description
+		}
+		data.Cards = append(data.Cards, pc)
+	}
+	return data
+}
+
+// promptPersona — образ толкователя, подставляемый в шаблоны (переменная LLM_PERSONA).
+var promptPersona = "профессиональная русскоязычная гадалка-таролог"
+
+// languageNames — названия языков по коду из профиля Telegram.
+var languageNames = map[string]string{
+	"ru": "русский",
+	"uk": "украинский",
+	"be": "белорусский",
+	"kk": "казахский",
+	"en": "английский",
+	"de": "немецкий",
+}
+
+// Функция languageName возвращает название языка по коду, по умолчанию — русский.
+func languageName(code string) string {
+	code = strings.ToLower(strings.SplitN(code, "-", 2)[0])
+	if name, ok := languageNames[code]; ok {
+		return name
+	}
+	return "русский"
+}
+
+// PromptLibrary — версионированные шаблоны запросов из каталога prompts.
+type PromptLibrary struct {
+	templates map[string]*template.Template // Ключ — "название.vВерсия"
+	latest    map[string]int                // Последняя версия каждого шаблона
+}
+
+// Функция loadPromptLibrary загружает и разбирает все шаблоны из каталога dir.
+func loadPromptLibrary(dir string) (*PromptLibrary, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения каталога шаблонов: %v", err)
+	}
+
+	lib := &PromptLibrary{
+		templates: make(map[string]*template.Template),
+		latest:    make(map[string]int),
+	}
+	funcs := template.FuncMap{
+		"inc": func(i int) int { return i + 1 },
+	}
+	for _, entry := range entries {
+		match := promptFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		name := match[1]
+		version, _ := strconv.Atoi(match[2])
+
+		text, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения шаблона %s: %v", entry.Name(), err)
+		}
+		key := promptKey(name, version)
+		tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка в шаблоне %s: %v", entry.Name(), err)
+		}
+		lib.templates[key] = tmpl
+		if version > lib.latest[name] {
+			lib.latest[name] = version
+		}
+	}
+
+	if len(lib.templates) == 0 {
+		return nil, fmt.Errorf("в каталоге %s нет шаблонов запросов", dir)
+	}
+	return lib, nil
+}
+
+// Функция promptKey строит ключ шаблона из названия и версии.
+func promptKey(name string, version int) string {
+	return fmt.Sprintf("%s.v%d", name, version)
+}
+
+// Resolve превращает ссылку на шаблон ("reading" или "reading.v1") в ключ конкретной версии.
+func (l *PromptLibrary) Resolve(ref string) (string, bool) {
+	if _, ok := l.templates[ref]; ok {
+		return ref, true
+	}
+	if version, ok := l.latest[ref]; ok {
+		return promptKey(ref, version), true
+	}
+	return "", false
+}
+
+// Render заполняет шаблон данными. Возвращает текст запроса и ключ использованной версии.
+func (l *PromptLibrary) Render(ref string, data PromptData) (string, string, error) {
+	key, ok := l.Resolve(ref)
+	if !ok {
+		return "", "", fmt.Errorf("шаблон запроса %q не найден", ref)
+	}
+	var b strings.Builder
+	if err := l.templates[key].Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("ошибка заполнения шаблона %s: %v", key, err)
+	}
+	return strings.TrimSpace(b.String()), key, nil
+}
+
+// Versions возвращает ключи всех загруженных шаблонов, отсортированные по имени.
+func (l *PromptLibrary) Versions() []string {
+	keys := make([]string, 0, len(l.templates))
+	for key := range l.templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckSpreads проверяет, что есть общие шаблоны (системный и уточняющего вопроса)
+// и для каждого расклада есть его шаблон запроса.
+func (l *PromptLibrary) CheckSpreads(book *SpreadBook) error {
+	for _, ref := range []string{systemPromptTemplate, followUpPromptTemplate} {
+		if _, ok := l.Resolve(ref); !ok {
+			return fmt.Errorf("шаблон %q не найден", ref)
+		}
+	}
+	for _, spread := range book.Spreads {
+		if _, ok := l.Resolve(spread.PromptTemplate()); !ok {
+			return fmt.Errorf("расклад %s: шаблон запроса %q не найден", spread.ID, spread.PromptTemplate())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimit — параметры «ведра с жетонами» для одного тарифного уровня:
+// в ведре помещается Burst жетонов, новый жетон появляется раз в Every.
+// Каждый запрос к модели (расклад или уточняющий вопрос) забирает один жетон.
+type RateLimit struct {
+	Burst int      `json:"burst"`
+	Every Duration `json:"every"`
+}
+
+// Duration — длительность, которая в JSON записывается строкой, например "10m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// QuotaDenied — отказ в запросе к модели и время, через которое стоит попробовать снова.
+type QuotaDenied struct {
+	Reason     string        // QuotaDaily — закончились расклады, QuotaRate — слишком частые запросы
+	RetryAfter time.Duration // Через сколько появится возможность сделать запрос (0 — сама не появится)
+}
+
+// Причины отказа.
+const (
+	QuotaDaily = "daily"
+	QuotaRate  = "rate"
+)
+
+func (d *QuotaDenied) Error() string {
+	if d.Reason == QuotaDaily {
+		if d.RetryAfter == 0 {
+			return "нет доступных раскладов, бесплатных раскладов нет"
+		}
+		return fmt.Sprintf("нет доступных раскладов, бесплатные обновятся через %v", d.RetryAfter)
+	}
+	return fmt.Sprintf("слишком частые запросы, следующий через %v", d.RetryAfter)
+}
+
+// bucketState — состояние ведра пользователя.
+type bucketState struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+}
+
+// Quotas ограничивает запросы пользователей к модели: дневной нормой и балансом из Ledger
+// и частотой запросов по уровню тарифа (бесплатный, оплаченный расклад, подписка).
+// Состояние вёдер хранится в памяти и сбрасывается в JSON-файл, ключ — ID пользователя.
+type Quotas struct {
+	mu      sync.Mutex
+	path    string               // Файл состояния (пусто — только в памяти)
+	ledger  *Ledger              // Баланс, по которому определяется уровень тарифа
+	limits  map[string]RateLimit // Ограничения по уровням: ReadingFree, ReadingPaid, ReadingSubscription
+	buckets map[int64]*bucketState
+}
+
+// Функция NewQuotas открывает состояние ограничений в файле path.
+func NewQuotas(path string, ledger *Ledger, limits map[string]RateLimit) (*Quotas, error) {
+	q := &Quotas{path: path, ledger: ledger, limits: limits, buckets: make(map[int64]*bucketState)}
+	if path == "" {
+		return q, nil
+	}
+
+	if err := loadJSONFile(path, &q.buckets); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// AcquireReading проверяет, можно ли сделать новый расклад, и забирает жетон из ведра.
+// Сам расклад списывается с баланса отдельно, после успешного толкования.
+// При отказе возвращает *QuotaDenied.
+func (q *Quotas) AcquireReading(userID int64, now time.Time) error {
+	tier, err := q.ledger.Allowance(userID, now)
+	if err != nil {
+		// Без бесплатной нормы расклады в полночь не появятся: ждать нечего, остаются только тарифы
+		denied := &QuotaDenied{Reason: QuotaDaily}
+		if q.ledger.FreeDaily() > 0 {
+			denied.RetryAfter = untilNextDay(now)
+		}
+		return denied
+	}
+	return q.take(userID, tier, now)
+}
+
+// AcquireFollowUp проверяет частоту уточняющих вопросов. Баланс они не расходуют,
+// но уровень тарифа определяет, как часто их можно задавать.
+func (q *Quotas) AcquireFollowUp(userID int64, now time.Time) error {
+	tier, err := q.ledger.Allowance(userID, now)
+	if err != nil {
+		tier = ReadingFree
+	}
+	return q.take(userID, tier, now)
+}
+
+// take забирает жетон из ведра пользователя по ограничению уровня tier.
+func (q *Quotas) take(userID int64, tier string, now time.Time) error {
+	limit, ok := q.limits[tier]
+	if !ok || limit.Burst <= 0 || limit.Every <= 0 {
+		return nil // Для уровня ограничение не задано
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bucket, ok := q.buckets[userID]
+	if !ok {
+		bucket = &bucketState{Tokens: float64(limit.Burst), Updated: now}
+		q.buckets[userID] = bucket
+	}
+
+	// Пополняем ведро за прошедшее время, но не больше ёмкости текущего уровня
+	every := time.Duration(limit.Every)
+	if elapsed := now.Sub(bucket.Updated); elapsed > 0 {
+		bucket.Tokens += float64(elapsed) / float64(every)
+	}
+	bucket.Tokens = min(bucket.Tokens, float64(limit.Burst))
+	bucket.Updated = now
+
+	if bucket.Tokens < 1 {
+		wait := time.Duration((1 - bucket.Tokens) * float64(every))
+		return &QuotaDenied{Reason: QuotaRate, RetryAfter: wait.Round(time.Second)}
+	}
+	bucket.Tokens--
+	return q.flush()
+}
+
+// flush записывает состояние вёдер на диск. Вызывается под блокировкой.
+func (q *Quotas) flush() error {
+	if q.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(q.buckets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации ограничений: %v", err)
+	}
+	return writeFileAtomic(q.path, data)
+}
+
+// Функция untilNextDay возвращает время до полуночи по времени сервера, когда обновляются бесплатные расклады.
+func untilNextDay(now time.Time) time.Duration {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
+
+// Функция formatWait записывает длительность для пользователя, например "2 ч 15 мин" или "40 с".
+func formatWait(d time.Duration) string {
+	d = d.Round(time.Second)
+	hours, minutes, seconds := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	switch {
+	case hours > 0:
+		return fmt.Sprintf("%d ч %d мин", hours, minutes)
+	case minutes > 0 && seconds > 0:
+		return fmt.Sprintf("%d мин %d с", minutes, seconds)
+	case minutes > 0:
+		return fmt.Sprintf("%d мин", minutes)
+	default:
+		return fmt.Sprintf("%d с", max(seconds, 1))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testLimits = map[string]RateLimit{
+	ReadingFree:         {Burst: 2, Every: Duration(10 * time.Minute)},
+	ReadingSubscription: {Burst: 5, Every: Duration(time.Minute)},
+}
+
+// Функция quotaDenied достаёт отказ из ошибки или останавливает тест.
+func quotaDenied(t *testing.T, err error, reason string) *QuotaDenied {
+	t.Helper()
+	var denied *QuotaDenied
+	if !errors.As(err, &denied) || denied.Reason != reason {
+		t.Fatalf("ожидался отказ %q, получено %v", reason, err)
+	}
+	return denied
+}
+
+func TestQuotasTokenBucket(t *testing.T) {
+	ledger, _ := NewLedger("", 1)
+	q, err := NewQuotas("", ledger, testLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+
+	// Полное ведро пропускает burst запросов подряд
+	for i := 0; i < 2; i++ {
+		if err := q.AcquireFollowUp(1, now); err != nil {
+			t.Fatalf("запрос %d: %v", i+1, err)
+		}
+	}
+	denied := quotaDenied(t, q.AcquireFollowUp(1, now.Add(time.Minute)), QuotaRate)
+	if denied.RetryAfter != 9*time.Minute {
+		t.Fatalf("RetryAfter = %v, ожидалось 9m", denied.RetryAfter)
+	}
+
+	// Отказ не тратит жетон: через Every ведро пополняется на один жетон
+	if err := q.AcquireFollowUp(1, now.Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	quotaDenied(t, q.AcquireFollowUp(1, now.Add(10*time.Minute)), QuotaRate)
+
+	// У других пользователей свои вёдра
+	if err := q.AcquireFollowUp(2, now); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Без бесплатной нормы расклады в полночь не появятся, поэтому и ждать нечего.
+func TestQuotasNoFreeReadings(t *testing.T) {
+	ledger, _ := NewLedger("", 0)
+	q, _ := NewQuotas("", ledger, testLimits)
+	denied := quotaDenied(t, q.AcquireReading(1, time.Now()), QuotaDaily)
+	if denied.RetryAfter != 0 {
+		t.Fatalf("RetryAfter = %v, при FREE_DAILY=0 ожидался 0", denied.RetryAfter)
+	}
+}
+
+func TestQuotasDailyAndTiers(t *testing.T) {
+	ledger, _ := NewLedger("", 1)
+	q, _ := NewQuotas("", ledger, testLimits)
+	now := time.Date(2026, 3, 1, 22, 30, 0, 0, time.Local)
+
+	if err := q.AcquireReading(1, now); err != nil {
+		t.Fatal(err)
+	}
+	ledger.Consume(1, now)
+	denied := quotaDenied(t, q.AcquireReading(1, now), QuotaDaily)
+	if denied.RetryAfter != 90*time.Minute {
+		t.Fatalf("RetryAfter = %v, ожидалось время до полуночи 1h30m", denied.RetryAfter)
+	}
+
+	// Подписка даёт свой уровень ограничений, а для уровня без ограничения запросы не ограничены
+	ledger.Credit(1, Tariff{ID: "month", Kind: TariffSubscription, Days: 30}, Payment{ChargeID: "m1", Time: now})
+	if err := q.AcquireReading(1, now); err != nil {
+		t.Fatal(err)
+	}
+	ledger.Credit(3, Tariff{ID: "pack5", Kind: TariffReadings, Readings: 5}, Payment{ChargeID: "p1", Time: now})
+	ledger.Consume(3, now)
+	for i := 0; i < 10; i++ {
+		if err := q.AcquireReading(3, now); err != nil {
+			t.Fatalf("уровень paid без ограничения, запрос %d: %v", i+1, err)
+		}
+	}
+}
+
+// Состояние вёдер переживает перезапуск.
+func TestQuotasPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quotas.json")
+	ledger, _ := NewLedger("", 1)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	q, _ := NewQuotas(path, ledger, testLimits)
+	q.AcquireFollowUp(1, now)
+	q.AcquireFollowUp(1, now)
+
+	reopened, err := NewQuotas(path, ledger, testLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	quotaDenied(t, reopened.AcquireFollowUp(1, now), QuotaRate)
+}
+
+func TestFormatWait(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                "1 с",
+		40 * time.Second: "40 с",
+		5 * time.Minute:  "5 мин",
+		90 * time.Second: "1 мин 30 с",
+		5*time.Hour + 12*time.Minute + 10*time.Second: "5 ч 12 мин",
+	}
+	for d, want := range tests {
+		if got := formatWait(d); got != want {
+			t.Errorf("formatWait(%v) = %q, ожидалось %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Текст для пользователя, когда толкование получить не удалось.
+const llmUnavailableText = "🔮 Карты сейчас молчат: не удалось получить толкование. Пожалуйста, попробуйте чуть позже."
+
+// Пометка для толкования, составленного без модели.
+const fallbackNotice = "⚠️ Толкователь сейчас недоступен, поэтому это автоматическое толкование по значениям карт.\n\n"
+
+// Меню расклада, толкование которого составлено без модели.
+const offlineFollowUpText = "Толкователь сейчас не на связи, поэтому уточняющие вопросы к этому раскладу недоступны. Сделайте новый расклад или вернитесь в меню."
+
+// readingImage — действие меню расклада: прислать расклад одной картинкой.
+const readingImage = "image"
+
+// readingMenuItems — пункты меню расклада в порядке кнопок. Подписи можно менять только здесь.
+var readingMenuItems = []menuItem{
+	{readingImage, "🖼 Картинка расклада"},
+	{menuAsk, "Новый расклад"},
+	{menuMain, backButtonLabel},
+}
+
+// maxQuestionLength — наибольшая длина вопроса и уточняющего вопроса в символах.
+// Это же число подставляется в инструкцию, поэтому они не расходятся.
+const maxQuestionLength = 200
+
+// Функция questionTooLong сообщает, длиннее ли текст maxQuestionLength символов.
+// Считаются руны, а не байты: иначе вопрос кириллицей обрезался бы вдвое раньше.
+func questionTooLong(text string) bool {
+	return utf8.RuneCountInString(text) > maxQuestionLength
+}
+
+// Глобальное ограничение числа уточняющих вопросов к одному раскладу (переменная MAX_FOLLOWUPS).
+var maxFollowUps = 3
+
+// ReadingSession — сделанный расклад, к которому пользователь может задавать уточняющие вопросы.
+// Хранится в сессии чата, пока чат находится в состоянии "reading".
+type ReadingSession struct {
+	SpreadID  string        `json:"spread_id"`
+	Question  string        `json:"question"`
+	Seed      int64         `json:"seed"`
+	Cards     []DrawnCard   `json:"cards"`
+	Turns     []ChatMessage `json:"turns"`             // Запросы и ответы по раскладу, начиная с первого толкования
+	FollowUps int           `json:"follow_ups"`        // Сколько уточняющих вопросов уже задано
+	Offline   bool          `json:"offline,omitempty"` // Толкование составлено без модели, уточняющие вопросы недоступны
+}
+
+// followUpsLeft возвращает, сколько уточняющих вопросов ещё можно задать к раскладу.
+func (r *ReadingSession) followUpsLeft() int {
+	if r == nil || r.Offline {
+		return 0
+	}
+	return max(0, maxFollowUps-r.FollowUps)
+}
+
+// Функция performReading делает расклад spread на вопрос question:
+// тянет карты по числу позиций, отправляет их пользователю и запрашивает толкование у модели.
+// После успешного толкования чат переходит в состояние "reading" для уточняющих вопросов.
+func performReading(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session, spread Spread, question string) {
+	// По умолчанию после расклада возвращаем пользователя в главное меню.
+	session.State = "main"
+	session.Reading = nil
+	session.PendingSpread = ""
+
+	// Проверяем баланс и частоту запросов до того, как тянуть карты
+	if !checkReadingAllowed(bot, message, session) {
+		return
+	}
+
+	// Вытягиваем из загруженной колоды столько карт, сколько позиций в раскладе
+	draw, err := drawer.Draw(deckRepo.Deck(), spread.CardCount())
+	if err != nil {
+		log.Printf("Ошибка расклада %s: %v", spread.ID, err)
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Извините, этот расклад сейчас недоступен."))
+		return
+	}
+
+	// Показываем вытянутые карты с кодом расклада
+	sendDrawnCards(bot, message.Chat.ID, session, spread, draw)
+
+	// Если у карт есть изображения, показываем их медиагруппой
+	for _, messageID := range sendCardImages(bot, message.Chat.ID, draw.Cards) {
+		session.rememberBotMessage(messageID)
+	}
+
+	// Заполняем шаблон запроса, выбранный для этого расклада
+	promptData := newPromptData(spread, question, draw.Cards, messageLanguage(message))
+	systemPrompt, _, err := prompts.Render(systemPromptTemplate, promptData)
+	if err != nil {
+		log.Printf("Ошибка подготовки системного запроса: %v", err)
+	}
+	userPrompt, promptVersion, err := prompts.Render(spread.PromptTemplate(), promptData)
+	if err != nil {
+		log.Printf("Ошибка подготовки запроса: %v", err)
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Извините, этот расклад сейчас недоступен."))
+		return
+	}
+
+	log.Printf("Расклад %s (код %d, шаблон %s), сообщение от пользователя: %s", spread.ID, draw.Seed, promptVersion, message.Text)
+
+	// Запрашиваем толкование и показываем его пользователю
+	interpretation, ok := renderInterpretation(bot, message.Chat.ID, session, InterpretRequest{
+		Question: question,
+		Spread:   spread,
+		Cards:    draw.Cards,
+		System:   systemPrompt,
+		Prompt:   userPrompt,
+	})
+	if !ok {
+		return
+	}
+
+	// Списываем расклад с баланса (толкование по шаблону бесплатно) и сохраняем его в историю пользователя
+	if !interpretation.Offline() {
+		chargeReading(message)
+	}
+	recordHistory(message, newHistoryEntry(spread, question, draw, interpretation))
+
+	// Запоминаем расклад, чтобы отвечать на уточняющие вопросы в его контексте
+	session.State = "reading"
+	session.Reading = &ReadingSession{
+		SpreadID: spread.ID,
+		Question: question,
+		Seed:     draw.Seed,
+		Cards:    draw.Cards,
+		Turns: []ChatMessage{
+			{Role: "user", Content: userPrompt},
+			{Role: "assistant", Content: interpretation.Text},
+		},
+		Offline: interpretation.Offline(),
+	}
+	session.rememberBotMessage(sendReadingMenu(bot, message.Chat.ID, session.Reading))
+}
+
+// Функция sendDrawnCards отправляет вытянутые карты по позициям расклада и код расклада.
+func sendDrawnCards(bot *tgbotapi.BotAPI, chatID int64, session *Session, spread Spread, draw Draw) {
+	// Собираем сообщение с картами по позициям расклада
+	cardMsg := "🔮 " + spread.Title + "\n\n"
+	for i, card := range draw.Cards { // Итерируемся по выбранным картам
+		position := spread.Positions[i]
+		if position.Description != "" {
+			position.Name += " (" + position.Description + ")"
+		}
+		cardMsg = cardMsg + position.Name + ":\n" + card.Title() + "\n" + card.Meaning() + "\n\n\n"
+	}
+
+	// Код расклада — зерно генератора, по нему расклад можно воспроизвести командой /replay.
+	// Большие расклады не помещаются в одно сообщение, поэтому текст режется на части.
+	for _, part := range splitMessage(cardMsg+fmt.Sprintf("Код расклада: %d", draw.Seed), telegramMessageLimit) {
+		msg := tgbotapi.NewMessage(chatID, part)
+		sentMsg, err := bot.Send(msg)
+		if err != nil {
+			log.Printf("Ошибка отправки сообщения: %v", err)
+		}
+		session.rememberBotMessage(sentMsg.MessageID)
+	}
+}
+
+// Функция handleReading обрабатывает сообщения в состоянии "reading": кнопки меню расклада
+// и уточняющие вопросы по последнему раскладу.
+func handleReading(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	action, _ := menuActionByLabel(readingMenuItems, message.Text)
+	if message.Text == "/start" {
+		action = menuMain
+	}
+	switch action {
+	// Кнопки "Назад в меню" и "Новый расклад" закрывают расклад и открывают главное меню или меню вопросов.
+	case menuMain, menuAsk:
+		openMenu(bot, message.Chat.ID, messageUserID(message), session, action)
+	// Кнопка "🖼 Картинка расклада" присылает расклад одной картинкой, которой удобно поделиться.
+	case readingImage:
+		if reading := session.Reading; reading != nil {
+			spread, _ := spreadBook.ByID(reading.SpreadID)
+			session.rememberBotMessage(sendSpreadImage(bot, message.Chat.ID, spread.Title, reading.Question, readingImageCards(spread, reading.Cards)))
+		}
+		session.rememberBotMessage(sendReadingMenu(bot, message.Chat.ID, session.Reading))
+	// Любой другой текст считаем уточняющим вопросом.
+	default:
+		reading := session.Reading
+		switch {
+		case reading == nil:
+			// Расклад потерян (например, сессия сохранена старой версией бота)
+			session.State = "main"
+			session.rememberBotMessage(sendMainMenu(bot, message.Chat.ID))
+		case reading.Offline:
+			session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID, offlineFollowUpText))
+		case reading.FollowUps >= maxFollowUps:
+			session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID,
+				"Уточняющие вопросы к этому раскладу закончились. Сделайте новый расклад или вернитесь в меню."))
+		case message.Text == "" || questionTooLong(message.Text):
+			session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID,
+				"Вы отправили слишком длинное сообщение, либо сообщение не текстовое."))
+		case strings.HasPrefix(message.Text, "/"):
+			session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID,
+				"Неизвестная команда. Напишите уточняющий вопрос текстом или выберите пункт меню."))
+		case checkFollowUpAllowed(bot, message, session):
+			answerFollowUp(bot, message, session)
+		}
+	}
+}
+
+// Функция answerFollowUp отвечает на уточняющий вопрос в контексте последнего расклада:
+// модель получает системный запрос, все предыдущие реплики по раскладу и новый вопрос.
+func answerFollowUp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	reading := session.Reading
+	spread, ok := spreadBook.ByID(reading.SpreadID)
+	if !ok {
+		spread = spreadBook.Default()
+	}
+
+	promptData := newPromptData(spread, message.Text, reading.Cards, messageLanguage(message))
+	systemPrompt, _, err := prompts.Render(systemPromptTemplate, promptData)
+	if err != nil {
+		log.Printf("Ошибка подготовки системного запроса: %v", err)
+	}
+	userPrompt, _, err := prompts.Render(followUpPromptTemplate, promptData)
+	if err != nil {
+		log.Printf("Ошибка подготовки уточняющего запроса: %v", err)
+		session.rememberBotMessage(sendReadingMenuText(bot, message.Chat.ID, "Извините, сейчас не получается ответить на уточняющий вопрос."))
+		return
+	}
+
+	log.Printf("Уточняющий вопрос к раскладу %s (код %d): %s", reading.SpreadID, reading.Seed, message.Text)
+
+	interpretation, ok := renderInterpretation(bot, message.Chat.ID, session, InterpretRequest{
+		Question: message.Text,
+		Spread:   spread,
+		Cards:    reading.Cards,
+		System:   systemPrompt,
+		History:  reading.Turns,
+		Prompt:   userPrompt,
+	})
+	if ok {
+		reading.FollowUps++
+		reading.Turns = append(reading.Turns,
+			ChatMessage{Role: "user", Content: userPrompt},
+			ChatMessage{Role: "assistant", Content: interpretation.Text},
+		)
+	}
+	session.rememberBotMessage(sendReadingMenu(bot, message.Chat.ID, reading))
+}
+
+// Функция renderInterpretation запрашивает толкование и показывает его пользователю по мере генерации.
+// Возвращает толкование и признак успеха; при неудаче пользователь уже получил понятное сообщение,
+// а подробности ошибки записаны в лог.
+func renderInterpretation(bot *tgbotapi.BotAPI, chatID int64, session *Session, req InterpretRequest) (Interpretation, bool) {
+	// Отправляем заглушку, которую будем редактировать по мере генерации ответа
+	renderer := newStreamRenderer(bot, chatID, "🔮 Толкую карты...", streamEditInterval)
+	session.rememberBotMessage(renderer.MessageID())
+
+	ctx, cancel := context.WithTimeout(context.Background(), llmTimeout)
+	defer cancel()
+
+	var interpretation Interpretation
+	var err error
+	if streamAnswers {
+		interpretation, err = interpretStream(ctx, interpreter, req, renderer.Update)
+	} else {
+		interpretation, err = interpreter.Interpret(ctx, req)
+	}
+
+	answer := interpretation.Text
+	switch {
+	case err != nil:
+		// Подробности ошибки — только в лог, пользователю — понятный текст
+		log.Printf("Ошибка толкования расклада %s для чата %d: %v", req.Spread.ID, chatID, err)
+		answer = llmUnavailableText
+	case answer == "":
+		// Проверяем, что ответ не пустой
+		answer = "Извините, я не смог обработать ваш запрос."
+		err = fmt.Errorf("пустой ответ")
+	case interpretation.Fallback:
+		// Модель недоступна — честно предупреждаем, что толкование составлено автоматически
+		answer = fallbackNotice + answer
+	}
+
+	// Показываем окончательный ответ пользователю.
+	for _, messageID := range renderer.Finish(answer) {
+		session.rememberBotMessage(messageID)
+	}
+	return interpretation, err == nil
+}
+
+// Функция sendReadingMenu предлагает задать уточняющий вопрос к раскладу reading, если это ещё можно,
+// или перейти к новому раскладу.
+func sendReadingMenu(bot *tgbotapi.BotAPI, chatID int64, reading *ReadingSession) int {
+	if reading != nil && reading.Offline {
+		return sendReadingMenuText(bot, chatID, offlineFollowUpText)
+	}
+	text := "Это был последний уточняющий вопрос к раскладу. Сделайте новый расклад или вернитесь в меню."
+	if remaining := reading.followUpsLeft(); remaining > 0 {
+		text = fmt.Sprintf("Можете задать уточняющий вопрос по этому раскладу (осталось: %d), сделать новый расклад или вернуться в меню.", remaining)
+	}
+	return sendReadingMenuText(bot, chatID, text)
+}
+
+// Функция sendReadingMenuText отправляет текст с клавиатурой меню расклада:
+// "🖼 Картинка расклада", "Новый расклад" и "Назад в меню".
+func sendReadingMenuText(bot *tgbotapi.BotAPI, chatID int64, text string) int {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = menuKeyboard(readingMenuItems)
+	// Отправляем сообщение и возвращаем его ID.
+	sentMsg, _ := bot.Send(msg)
+	return sentMsg.MessageID
+}
+
+// Функция messageLanguage возвращает код языка отправителя сообщения.
+func messageLanguage(message *tgbotapi.Message) string {
+	if message.From == nil {
+		return ""
+	}
+	return message.From.LanguageCode
+}
+
+// Функция readingImageCards сопоставляет вытянутые карты с позициями расклада для картинки.
+func readingImageCards(spread Spread, cards []DrawnCard) []SpreadImageCard {
+	result := make([]SpreadImageCard, len(cards))
+	for i, card := range cards {
+		result[i].Card = card
+		if i < len(spread.Positions) {
+			result[i].Position = spread.Positions[i].Name
+		}
+	}
+	return result
+}
+
+// Функция sendSpreadImage рисует расклад картинкой и отправляет её в чат.
+// Возвращает ID сообщения с картинкой или 0, если картинку не удалось нарисовать или отправить.
+func sendSpreadImage(bot *tgbotapi.BotAPI, chatID int64, title, question string, cards []SpreadImageCard) int {
+	data, err := renderSpreadImage(title, question, cards)
+	if err != nil {
+		log.Printf("Ошибка рисования картинки расклада: %v", err)
+		return 0
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "spread.png", Bytes: data})
+	photo.Caption = "🔮 " + title
+	sentMsg, err := bot.Send(photo)
+	if err != nil {
+		log.Printf("Ошибка отправки картинки расклада: %v", err)
+	}
+	return sentMsg.MessageID
+}
+
+// Функция messageUserID возвращает ID отправителя сообщения, а если он неизвестен — ID чата.
+func messageUserID(message *tgbotapi.Message) int64 {
+	if message.From == nil {
+		return message.Chat.ID
+	}
+	return message.From.ID
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Длина вопроса считается в символах: вопрос кириллицей в пределах лимита принимается.
+func TestQuestionTooLongCountsRunes(t *testing.T) {
+	if questionTooLong(strings.Repeat("я", maxQuestionLength)) {
+		t.Errorf("вопрос из %d кириллических символов не должен считаться длинным", maxQuestionLength)
+	}
+	if !questionTooLong(strings.Repeat("я", maxQuestionLength+1)) {
+		t.Errorf("вопрос из %d символов должен считаться длинным", maxQuestionLength+1)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// replayUsage — подсказка к команде /replay.
+const replayUsage = "Использование: /replay <код расклада> [ID расклада]\n" +
+	"Код показывается под каждым раскладом. Если расклад есть в вашей истории, ID указывать не нужно."
+
+// Функция handleReplay обрабатывает команду "/replay <код> [ID расклада]" и показывает карты
+// расклада по его коду без толкования. Если расклад с этим кодом есть в истории пользователя,
+// показываются сохранённые карты: колоду могли изменить, и по коду вытянулись бы другие.
+// Иначе карты заново тянутся из текущей колоды, о чём пользователь предупреждается.
+func handleReplay(bot *tgbotapi.BotAPI, message *tgbotapi.Message, session *Session) {
+	fields := strings.Fields(message.Text)
+	if len(fields) < 2 || len(fields) > 3 {
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, replayUsage))
+		return
+	}
+	seed, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || seed < 0 {
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Код расклада — это число.\n"+replayUsage))
+		return
+	}
+
+	if entry, ok := replayHistoryEntry(messageUserID(message), seed); ok && (len(fields) == 2 || fields[2] == entry.SpreadID) {
+		spread, draw := historyDraw(entry)
+		sendDrawnCards(bot, message.Chat.ID, session, spread, draw)
+		return
+	}
+
+	spread, ok := Spread{}, false
+	if len(fields) == 3 {
+		spread, ok = spreadBook.ByID(fields[2])
+	}
+	if !ok {
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Расклад не найден.\n"+replayUsage))
+		return
+	}
+
+	draw, err := drawer.Replay(deckRepo.Deck(), spread.CardCount(), seed)
+	if err != nil {
+		log.Printf("Ошибка воспроизведения расклада %s (код %d): %v", spread.ID, seed, err)
+		session.rememberBotMessage(sendMessage(bot, message.Chat.ID, "Извините, этот расклад сейчас недоступен."))
+		return
+	}
+	session.rememberBotMessage(sendMessage(bot, message.Chat.ID,
+		"Этого расклада нет в вашей истории, поэтому карты вытянуты по коду из текущей колоды. Если колоду меняли, они могут отличаться от исходных."))
+	sendDrawnCards(bot, message.Chat.ID, session, spread, draw)
+}
+
+// Функция replayHistoryEntry находит в истории пользователя расклад с кодом seed.
+func replayHistoryEntry(userID, seed int64) (HistoryEntry, bool) {
+	for _, entry := range history.List(userID) {
+		if entry.Seed == seed {
+			return entry, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Функция historyDraw восстанавливает расклад из записи истории в том виде, в каком он был сделан:
+// карты и их положения берутся из записи, а не тянутся заново. Если расклад с тех пор изменился
+// или удалён, позиции восстанавливаются по названиям, сохранённым в истории.
+func historyDraw(entry HistoryEntry) (Spread, Draw) {
+	spread, ok := spreadBook.ByID(entry.SpreadID)
+	if !ok || spread.CardCount() != len(entry.Cards) {
+		spread = Spread{ID: entry.SpreadID, Positions: make([]SpreadPosition, len(entry.Cards))}
+		for i, card := range entry.Cards {
+			spread.Positions[i] = SpreadPosition{Name: card.Position}
+		}
+	}
+	spread.Title = entry.SpreadTitle
+
+	draw := Draw{Seed: entry.Seed}
+	for _, card := range historyImageCards(entry) {
+		draw.Cards = append(draw.Cards, card.Card)
+	}
+	return spread, draw
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Функция setupReplay подставляет колоду из tarocards.json, расклады и пустую историю в памяти.
+func setupReplay(t *testing.T) {
+	t.Helper()
+	oldDeck, oldDrawer, oldHistory, oldBook := deckRepo, drawer, history, spreadBook
+	t.Cleanup(func() { deckRepo, drawer, history, spreadBook = oldDeck, oldDrawer, oldHistory, oldBook })
+
+	repo, err := NewDeckRepository("tarocards.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book, err := loadSpreads("spreads.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deckRepo, drawer, history, spreadBook = repo, NewDrawer(), NewMemoryHistoryStore(10), book
+}
+
+// Функция replayText выполняет команду text и возвращает весь отправленный текст.
+func replayText(t *testing.T, userID int64, text string) string {
+	t.Helper()
+	bot, fake := newFakeBot(t)
+	var session Session
+	handleReplay(bot, &tgbotapi.Message{From: &tgbotapi.User{ID: userID}, Chat: &tgbotapi.Chat{ID: userID}, Text: text}, &session)
+	var sent []string
+	for _, form := range fake.calls("sendMessage") {
+		sent = append(sent, form.Get("text"))
+	}
+	return strings.Join(sent, "\n")
+}
+
+// Расклад из истории показывается с сохранёнными картами, даже если по коду из текущей колоды
+// вытянулись бы другие (например, после /reload_deck).
+func TestReplayShowsStoredCards(t *testing.T) {
+	setupReplay(t)
+	const userID, seed = 7, 12345
+
+	spread, _ := spreadBook.ByID("today")
+	draw, err := drawer.Replay(deckRepo.Deck(), spread.CardCount(), seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// В истории записаны другие карты: так выглядит расклад, сделанный до смены колоды
+	stored := draw
+	stored.Cards = append([]DrawnCard(nil), draw.Cards...)
+	stored.Cards[0] = DrawnCard{Card: Card{ID: "old-card", Name: "Карта прежней колоды"}, Reversed: true}
+	history.Add(userID, newHistoryEntry(spread, "вопрос", stored, Interpretation{Text: "толкование"}))
+
+	text := replayText(t, userID, "/replay 12345")
+	if !strings.Contains(text, "Карта прежней колоды (перевёрнутое положение)") {
+		t.Errorf("не показана сохранённая карта:\n%s", text)
+	}
+	if strings.Contains(text, draw.Cards[0].Card.Name+" (") {
+		t.Errorf("показана карта из текущей колоды вместо сохранённой:\n%s", text)
+	}
+	if strings.Contains(text, "текущей колоды") {
+		t.Errorf("для расклада из истории не нужно предупреждение о колоде:\n%s", text)
+	}
+}
+
+// Код не из истории тянется из текущей колоды, и пользователь об этом предупреждается.
+func TestReplayWithoutHistoryWarnsAboutDeck(t *testing.T) {
+	setupReplay(t)
+
+	text := replayText(t, 7, "/replay 12345 today")
+	if !strings.Contains(text, "текущей колоды") || !strings.Contains(text, "Код расклада: 12345") {
+		t.Errorf("ожидались предупреждение и карты расклада:\n%s", text)
+	}
+	if text := replayText(t, 7, "/replay 12345"); !strings.Contains(text, "Расклад не найден") {
+		t.Errorf("без ID расклада и истории ожидалась подсказка:\n%s", text)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Session хранит состояние диалога с конкретным чатом.
+// Возможные состояния: "main", "question", "reading", "encyclopedia", "instruction", "tariffs".
+// "main" — главное меню; "question" — режим для ввода вопроса; "reading" — уточняющие вопросы к последнему раскладу;
+// "encyclopedia" — справочник значений карт; "instruction"/"tariffs" — режимы просмотра инструкций и тарифов.
+type Session struct {
+	State string `json:"state"` // Текущее состояние чата
+	// Расклад, выбранный кнопкой и ждущий вопроса пользователя (только в состоянии "question").
+	PendingSpread string `json:"pending_spread,omitempty"`
+	// Последний расклад, к которому можно задавать уточняющие вопросы (только в состоянии "reading").
+	Reading *ReadingSession `json:"reading,omitempty"`
+	// Инструкция ещё не показывалась: флаг ставится только новым чатам, поэтому сессии,
+	// сохранённые до появления автопоказа инструкции, её при /start не получают.
+	GuidePending bool `json:"guide_pending,omitempty"`
+	// ID сообщений, из которых состоит последний ответ бота в этом чате.
+	// Нужны режиму чистого чата, чтобы удалить их при следующем сообщении пользователя.
+	BotMessageIDs []int `json:"bot_message_ids,omitempty"`
+}
+
+// rememberBotMessage запоминает сообщение бота как часть последнего ответа.
+// Нулевой ID (сообщение не отправилось) игнорируется. Без режима чистого чата ID не нужны
+// и не запоминаются, иначе список рос бы бесконечно и каждый раз записывался в файл сессий.
+func (s *Session) rememberBotMessage(messageID int) {
+	if messageID != 0 && cleanChat {
+		s.BotMessageIDs = append(s.BotMessageIDs, messageID)
+	}
+}
+
+// SessionStore — хранилище сессий, ключ — ID чата.
+type SessionStore interface {
+	// Get возвращает сессию чата и признак того, что она существует.
+	Get(chatID int64) (Session, bool)
+	// Set сохраняет сессию чата.
+	Set(chatID int64, session Session) error
+	// Delete удаляет сессию чата.
+	Delete(chatID int64) error
+}
+
+// MemorySessionStore хранит сессии только в памяти процесса.
+// Подходит для разработки: после перезапуска все чаты возвращаются в главное меню.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[int64]Session
+}
+
+// NewMemorySessionStore создаёт пустое хранилище сессий в памяти.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[int64]Session)}
+}
+
+func (s *MemorySessionStore) Get(chatID int64) (Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[chatID]
+	return session, ok
+}
+
+func (s *MemorySessionStore) Set(chatID int64, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[chatID] = session
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, chatID)
+	return nil
+}
+
+// FileSessionStore хранит сессии в памяти и сбрасывает их в JSON-файл при каждом изменении,
+// поэтому состояние чатов переживает перезапуск бота.
+type FileSessionStore struct {
+	mu       sync.RWMutex
+	path     string
+	sessions map[int64]Session
+}
+
+// NewFileSessionStore открывает хранилище сессий в файле path.
+func NewFileSessionStore(path string) (*FileSessionStore, error) {
+	s := &FileSessionStore{path: path, sessions: make(map[int64]Session)}
+
+	if err := loadJSONFile(path, &s.sessions); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSessionStore) Get(chatID int64) (Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[chatID]
+	return session, ok
+}
+
+func (s *FileSessionStore) Set(chatID int64, session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[chatID] = session
+	return s.flush()
+}
+
+func (s *FileSessionStore) Delete(chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, chatID)
+	return s.flush()
+}
+
+// flush записывает все сессии на диск. Вызывается под блокировкой.
+func (s *FileSessionStore) flush() error {
+	data, err := json.MarshalIndent(s.sessions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации сессий: %v", err)
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// loadJSONFile читает JSON-файл path в v. Отсутствующий или пустой файл — не ошибка:
+// хранилище начинается пустым (v не меняется), а файл создаст первая запись через writeFileAtomic.
+func loadJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка чтения файла %s: %v", path, err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("ошибка разбора файла %s: %v", path, err)
+	}
+	return nil
+}
+
+// writeFileAtomic записывает данные во временный файл и переименовывает его,
+// чтобы при падении процесса на диске не остался наполовину записанный файл.
+func writeFileAtomic(path string, data []byte) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("ошибка создания каталога %s: %v", dir, err)
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи файла %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("ошибка переименования файла %s: %v", tmp, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content *string // nil — файла нет
+		want    int     // Сколько записей загружено
+		wantErr bool
+	}{
+		{"файла нет", nil, 0, false},
+		{"пустой файл", stringPtr(""), 0, false},
+		{"записи", stringPtr(`{"1": {"state": "main"}, "2": {"state": "question"}}`), 2, false},
+		{"испорченный файл", stringPtr(`{"1":`), 0, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, filepath.Base(t.Name())+".json")
+			if tt.content != nil {
+				if err := os.WriteFile(path, []byte(*tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sessions := make(map[int64]Session)
+			err := loadJSONFile(path, &sessions)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("случай %d: ошибка %v, ожидалась ошибка: %v", i, err, tt.wantErr)
+			}
+			if len(sessions) != tt.want {
+				t.Errorf("загружено %d записей, ожидалось %d", len(sessions), tt.want)
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string { return &s }
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+)
+
+// Размеры картинки расклада в пикселях.
+const (
+	spreadImageCardWidth  = 200 // Ширина карты
+	spreadImageCardHeight = 320 // Высота карты
+	spreadImageCaption    = 130 // Высота подписи под картой (позиция и краткое значение)
+	spreadImageGap        = 30  // Промежуток между картами и отступ от краёв
+	spreadImageHeader     = 110 // Высота заголовка с названием расклада и вопросом
+	spreadImageColumns    = 5   // Сколько карт помещается в один ряд
+)
+
+// Цвета картинки расклада.
+var (
+	spreadImageBackground = color.RGBA{0x1c, 0x14, 0x2e, 0xff} // Тёмно-фиолетовый фон
+	spreadImageCardFill   = color.RGBA{0x3b, 0x2a, 0x5c, 0xff} // Заливка карты-заглушки
+	spreadImageGold       = color.RGBA{0xd8, 0xb4, 0x5a, 0xff} // Рамка карты и заголовок
+	spreadImageText       = color.RGBA{0xee, 0xe8, 0xf5, 0xff} // Основной текст
+	spreadImageMuted      = color.RGBA{0xb0, 0xa4, 0xc8, 0xff} // Второстепенный текст
+)
+
+// SpreadImageCard — карта на картинке расклада вместе с названием позиции.
+type SpreadImageCard struct {
+	Position string
+	Card     DrawnCard
+}
+
+// spreadFonts — шрифты картинки расклада. Шрифты Go встроены в программу и содержат кириллицу,
+// поэтому картинка рисуется без сети и без системных шрифтов.
+// font.Face нельзя использовать из нескольких горутин, поэтому начертания создаются для каждой картинки.
+type spreadFonts struct {
+	title, question, cardName, caption, small font.Face
+}
+
+var (
+	spreadFontsOnce sync.Once
+	spreadRegular   *opentype.Font // Разобранные шрифты общие: они только читаются
+	spreadBold      *opentype.Font
+	spreadFontsErr  error
+)
+
+// Функция newSpreadFonts создаёт начертания для одной картинки.
+// Встроенные шрифты разбираются один раз за время работы бота.
+func newSpreadFonts() (*spreadFonts, error) {
+	spreadFontsOnce.Do(func() {
+		spreadRegular, spreadFontsErr = opentype.Parse(goregular.TTF)
+		if spreadFontsErr == nil {
+			spreadBold, spreadFontsErr = opentype.Parse(gobold.TTF)
+		}
+		if spreadFontsErr != nil {
+			spreadFontsErr = fmt.Errorf("ошибка разбора шрифта: %v", spreadFontsErr)
+		}
+	})
+	if spreadFontsErr != nil {
+		return nil, spreadFontsErr
+	}
+
+	var err error
+	face := func(f *opentype.Font, size float64) font.Face {
+		if err != nil {
+			return nil
+		}
+		var ff font.Face
+		ff, err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		return ff
+	}
+	fonts := &spreadFonts{
+		title:    face(spreadBold, 34),
+		question: face(spreadRegular, 20),
+		cardName: face(spreadBold, 20),
+		caption:  face(spreadBold, 17),
+		small:    face(spreadRegular, 15),
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания шрифта: %v", err)
+	}
+	return fonts, nil
+}
+
+// Функция renderSpreadImage рисует расклад в PNG: заголовок с вопросом, карты рядами
+// по spreadImageColumns штук и под каждой картой — позицию и краткое значение.
+// Карты с изображением на диске рисуются картинкой, остальные — оформленной заглушкой.
+func renderSpreadImage(title, question string, cards []SpreadImageCard) ([]byte, error) {
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("в раскладе нет карт")
+	}
+	fonts, err := newSpreadFonts()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := min(len(cards), spreadImageColumns)
+	rows := (len(cards) + columns - 1) / columns
+	cellHeight := spreadImageCardHeight + spreadImageCaption
+	width := spreadImageGap + columns*(spreadImageCardWidth+spreadImageGap)
+	height := spreadImageHeader + rows*(cellHeight+spreadImageGap)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(spreadImageBackground), image.Point{}, draw.Src)
+
+	// Заголовок: название расклада и вопрос
+	drawCentered(img, fonts.title, spreadImageGold, title, width/2, 50)
+	if question != "" {
+		line := truncateToWidth(fonts.question, "«"+question+"»", width-2*spreadImageGap)
+		drawCentered(img, fonts.question, spreadImageMuted, line, width/2, 85)
+	}
+
+	for i, c := range cards {
+		// Последний неполный ряд выравниваем по центру
+		row, col := i/columns, i%columns
+		inRow := columns
+		if row == rows-1 && len(cards)%columns != 0 {
+			inRow = len(cards) % columns
+		}
+		offset := (columns - inRow) * (spreadImageCardWidth + spreadImageGap) / 2
+		x := spreadImageGap + offset + col*(spreadImageCardWidth+spreadImageGap)
+		y := spreadImageHeader + row*(cellHeight+spreadImageGap)
+
+		cardRect := image.Rect(x, y, x+spreadImageCardWidth, y+spreadImageCardHeight)
+		if !drawCardImage(img, cardRect, c.Card) {
+			drawCardPlaceholder(img, fonts, cardRect, i+1, c.Card)
+		}
+
+		// Подпись: позиция и краткое значение карты
+		captionY := y + spreadImageCardHeight + 24
+		if c.Position != "" {
+			drawCentered(img, fonts.caption, spreadImageGold, truncateToWidth(fonts.caption, c.Position, spreadImageCardWidth), x+spreadImageCardWidth/2, captionY)
+			captionY += 22
+		}
+		lines := wrapText(fonts.small, shortMeaning(c.Card.Meaning()), spreadImageCardWidth)
+		for j, line := range lines {
+			if j == 4 {
+				break
+			}
+			if j == 3 && len(lines) > 4 {
+				line = truncateToWidth(fonts.small, line+"…", spreadImageCardWidth)
+			}
+			drawCentered(img, fonts.small, spreadImageText, line, x+spreadImageCardWidth/2, captionY+j*19)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("ошибка кодирования PNG: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Функция drawCardImage рисует изображение карты из файла, вписывая его в рамку rect.
+// Перевёрнутая карта рисуется вверх ногами. Возвращает false, если у карты нет
+// изображения на диске или его не удалось прочитать, — тогда рисуется заглушка.
+func drawCardImage(img *image.RGBA, rect image.Rectangle, card DrawnCard) bool {
+	if !isCardImagePath(card.Card.Image) {
+		return false
+	}
+	file, err := os.Open(card.Card.Image)
+	if err != nil {
+		log.Printf("Ошибка открытия изображения карты %s: %v", card.Card.ID, err)
+		return false
+	}
+	defer file.Close()
+	src, _, err := image.Decode(file)
+	if err != nil {
+		log.Printf("Ошибка чтения изображения карты %s: %v", card.Card.ID, err)
+		return false
+	}
+
+	draw.Draw(img, rect, image.NewUniform(spreadImageGold), image.Point{}, draw.Src)
+	inner := rect.Inset(4)
+	scaled := image.NewRGBA(image.Rect(0, 0, inner.Dx(), inner.Dy()))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+	if card.Reversed {
+		scaled = rotate180(scaled)
+	}
+	draw.Draw(img, inner, scaled, image.Point{}, draw.Over)
+	return true
+}
+
+// Функция rotate180 возвращает копию изображения, повёрнутую на 180 градусов.
+func rotate180(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	rotated := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			rotated.Set(w-1-x, h-1-y, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return rotated
+}
+
+// Функция drawCardPlaceholder рисует оформленную заглушку карты: рамку, номер позиции,
+// название карты и её положение. Перевёрнутая карта отмечается стрелкой вниз.
+func drawCardPlaceholder(img *image.RGBA, fonts *spreadFonts, rect image.Rectangle, number int, card DrawnCard) {
+	draw.Draw(img, rect, image.NewUniform(spreadImageGold), image.Point{}, draw.Src)
+	draw.Draw(img, rect.Inset(4), image.NewUniform(spreadImageCardFill), image.Point{}, draw.Src)
+	// Внутренняя тонкая рамка, как на рубашке карты
+	inner := rect.Inset(12)
+	draw.Draw(img, inner, image.NewUniform(spreadImageGold), image.Point{}, draw.Src)
+	draw.Draw(img, inner.Inset(1), image.NewUniform(spreadImageCardFill), image.Point{}, draw.Src)
+
+	centerX := rect.Min.X + rect.Dx()/2
+	drawCentered(img, fonts.caption, spreadImageGold, fmt.Sprint(number), centerX, rect.Min.Y+40)
+
+	lines := wrapText(fonts.cardName, card.Card.Name, rect.Dx()-36)
+	top := rect.Min.Y + rect.Dy()/2 - len(lines)*24/2 + 12
+	for i, line := range lines {
+		drawCentered(img, fonts.cardName, spreadImageText, line, centerX, top+i*24)
+	}
+
+	orientation := "▲ прямая"
+	if card.Reversed {
+		orientation = "▼ перевёрнутая"
+	}
+	drawCentered(img, fonts.small, spreadImageMuted, orientation, centerX, rect.Max.Y-30)
+}
+
+// Функция drawCentered выводит строку так, чтобы её середина пришлась на centerX, а базовая линия — на y.
+func drawCentered(img *image.RGBA, face font.Face, c color.Color, text string, centerX, y int) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face}
+	d.Dot = fixed.P(centerX-d.MeasureString(text).Round()/2, y)
+	d.DrawString(text)
+}
+
+// Функция wrapText разбивает текст на строки не шире width пикселей по границам слов.
+func wrapText(face font.Face, text string, width int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Round() > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = truncateToWidth(face, candidate, width)
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Функция truncateToWidth обрезает строку с многоточием, чтобы она помещалась в width пикселей.
+func truncateToWidth(face font.Face, text string, width int) string {
+	if font.MeasureString(face, text).Round() <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "…"; font.MeasureString(face, candidate).Round() <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Функция shortMeaning возвращает первое предложение значения карты, но не длиннее 120 символов.
+func shortMeaning(meaning string) string {
+	meaning = strings.TrimSpace(meaning)
+	if i := strings.IndexAny(meaning, ".!?"); i > 0 {
+		meaning = meaning[:i+1]
+	}
+	if utf8.RuneCountInString(meaning) > 120 {
+		meaning = string([]rune(meaning)[:119]) + "…"
+	}
+	return meaning
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+// Картинки разных чатов рисуются параллельно; запускать с -race.
+func TestRenderSpreadImageConcurrent(t *testing.T) {
+	cards := []SpreadImageCard{
+		{Position: "Прошлое", Card: DrawnCard{Card: Card{ID: "m00", Name: "Шут", Upright: "Начало пути"}}},
+		{Position: "Настоящее", Card: DrawnCard{Card: Card{ID: "m01", Name: "Маг", Reversed: "Обман"}, Reversed: true}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := renderSpreadImage("Расклад", "Что меня ждёт?", cards)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+				t.Errorf("картинка не разбирается как PNG: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// SpreadPosition описывает одну позицию карты в раскладе.
+type SpreadPosition struct {
+	Name        string `json:"name"`        // Короткое название позиции, например "Прошлое"
+	Description string `json:"description"` // Что означает карта в этой позиции
+}
+
+// Spread — именованный расклад: сколько карт тянуть, что означает каждая позиция
+// и какую дополнительную инструкцию получает модель.
+type Spread struct {
+	ID        string           `json:"id"`       // Идентификатор расклада
+	Title     string           `json:"title"`    // Название расклада для пользователя
+	Button    string           `json:"button"`   // Текст кнопки в меню вопросов (пусто — расклад не показывается в меню)
+	Question  string           `json:"question"` // Вопрос, который задаётся от имени пользователя при выборе кнопки
+	Positions []SpreadPosition `json:"positions"`
+	// После нажатия кнопки бот ждёт вопрос пользователя и толкует расклад по нему, а не по Question.
+	AskQuestion bool `json:"ask_question,omitempty"`
+	// Расклад «да или нет»: запасное толкование отвечает по положению первой карты.
+	YesNo bool `json:"yes_no,omitempty"`
+	// Вступление запасного толкования, составленного без модели (пусто — общее вступление).
+	Intro string `json:"intro,omitempty"`
+	// Инструкция для модели, специфичная для расклада; подставляется в шаблон запроса как .Instruction.
+	Instruction string `json:"instruction"`
+	// Шаблон запроса из каталога prompts: "reading" — последняя версия, "reading.v1" — конкретная.
+	// Если не задан, используется defaultPromptTemplate.
+	Template string `json:"template,omitempty"`
+}
+
+// CardCount возвращает число карт в раскладе — по одной на позицию.
+func (s Spread) CardCount() int {
+	return len(s.Positions)
+}
+
+// PromptTemplate возвращает ссылку на шаблон запроса для расклада.
+func (s Spread) PromptTemplate() string {
+	if s.Template == "" {
+		return defaultPromptTemplate
+	}
+	return s.Template
+}
+
+// SpreadBook — набор раскладов, загруженный из файла spreads.json.
+type SpreadBook struct {
+	DefaultSpread string   `json:"default_spread"` // ID расклада для вопросов, введённых вручную
+	Spreads       []Spread `json:"spreads"`        // Расклады в порядке кнопок меню
+}
+
+// Функция loadSpreads загружает и проверяет описания раскладов из JSON-файла.
+func loadSpreads(filename string) (*SpreadBook, error) {
+	// Читаем содержимое файла
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var book SpreadBook
+	if err := json.Unmarshal(file, &book); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %v", filename, err)
+	}
+
+	ids := make(map[string]bool)
+	buttons := make(map[string]bool)
+	for i, spread := range book.Spreads {
+		switch {
+		case spread.ID == "":
+			return nil, fmt.Errorf("расклад №%d: не задан id", i+1)
+		case ids[spread.ID]:
+			return nil, fmt.Errorf("расклад %s описан дважды", spread.ID)
+		case spread.Title == "":
+			return nil, fmt.Errorf("расклад %s: не задано название", spread.ID)
+		case spread.CardCount() == 0:
+			return nil, fmt.Errorf("расклад %s: нет ни одной позиции", spread.ID)
+		case spread.Button != "" && buttons[spread.Button]:
+			return nil, fmt.Errorf("расклад %s: кнопка %q уже используется", spread.ID, spread.Button)
+		case spread.Button != "" && spread.Question == "" && !spread.AskQuestion:
+			return nil, fmt.Errorf("расклад %s: для кнопки не задан вопрос и не включён ask_question", spread.ID)
+		}
+		ids[spread.ID] = true
+		if spread.Button != "" {
+			buttons[spread.Button] = true
+		}
+	}
+
+	if !ids[book.DefaultSpread] {
+		return nil, fmt.Errorf("расклад по умолчанию %q не найден", book.DefaultSpread)
+	}
+	return &book, nil
+}
+
+// ByButton находит расклад по тексту нажатой кнопки.
+func (b *SpreadBook) ByButton(text string) (Spread, bool) {
+	for _, spread := range b.Spreads {
+		if spread.Button != "" && spread.Button == text {
+			return spread, true
+		}
+	}
+	return Spread{}, false
+}
+
+// ByID находит расклад по идентификатору.
+func (b *SpreadBook) ByID(id string) (Spread, bool) {
+	for _, spread := range b.Spreads {
+		if spread.ID == id {
+			return spread, true
+		}
+	}
+	return Spread{}, false
+}
+
+// Default возвращает расклад для вопросов, введённых вручную.
+func (b *SpreadBook) Default() Spread {
+	spread, _ := b.ByID(b.DefaultSpread)
+	return spread
+}
+
+// Buttons возвращает тексты кнопок раскладов в порядке их описания в файле.
+func (b *SpreadBook) Buttons() []string {
+	var buttons []string
+	for _, spread := range b.Spreads {
+		if spread.Button != "" {
+			buttons = append(buttons, spread.Button)
+		}
+	}
+	return buttons
+}
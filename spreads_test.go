@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Расклады, которые толкуются по вопросу пользователя, не должны подставлять вопрос-заглушку,
+// а инструкция должна собираться с флагом ask_question.
+func TestSpreadsAskQuestion(t *testing.T) {
+	book, err := loadSpreads("spreads.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"yes_no", "horseshoe", "celtic_cross"} {
+		spread, ok := book.ByID(id)
+		if !ok {
+			t.Fatalf("расклад %s не найден", id)
+		}
+		if !spread.AskQuestion || spread.Question != "" {
+			t.Errorf("расклад %s: AskQuestion = %v, Question = %q", id, spread.AskQuestion, spread.Question)
+		}
+	}
+
+	g, err := loadGuide("instruction.json", guideData{Spreads: book.Spreads, Default: book.Default()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all strings.Builder
+	for _, page := range g.Pages {
+		all.WriteString(page.Text)
+	}
+	if !strings.Contains(all.String(), "Бот попросит написать ваш вопрос") {
+		t.Error("инструкция не говорит, что для части раскладов нужен вопрос")
+	}
+}
+
+func TestLoadSpreadsRequiresQuestionForButton(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spreads.json")
+	data := `{"default_spread": "a", "spreads": [{"id": "a", "title": "A", "button": "A", "positions": [{"name": "1"}]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSpreads(path); err == nil {
+		t.Error("кнопка без вопроса и без ask_question должна быть ошибкой")
+	}
+}
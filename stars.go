@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Функция refundStarPayment возвращает пользователю звёзды за платёж chargeID методом Bot API refundStarPayment.
+// В используемой версии библиотеки для этого метода нет готовой конфигурации, поэтому запрос собирается вручную.
+func refundStarPayment(bot *tgbotapi.BotAPI, userID int64, chargeID string) error {
+	params := make(tgbotapi.Params)
+	params.AddNonZero64("user_id", userID)
+	params.AddNonEmpty("telegram_payment_charge_id", chargeID)
+	if _, err := bot.MakeRequest("refundStarPayment", params); err != nil {
+		return fmt.Errorf("refundStarPayment: %v", err)
+	}
+	return nil
+}
+
+// Функция refundPayment возвращает платёж в звёздах по его telegram_payment_charge_id
+// и списывает с баланса пользователя то, что было за него зачислено.
+// Возвращает текст для администратора. Платежи в рублях возвращаются через платёжного провайдера.
+func refundPayment(bot *tgbotapi.BotAPI, chargeID string) string {
+	userID, payment, ok := ledger.FindPayment(chargeID)
+	switch {
+	case !ok:
+		return fmt.Sprintf("Платёж %s не найден.", chargeID)
+	case payment.Refunded:
+		return fmt.Sprintf("Платёж %s уже возвращён.", chargeID)
+	case payment.Currency != starsCurrency:
+		return fmt.Sprintf("Платёж %s оплачен в %s: вернуть его можно только через платёжного провайдера.", chargeID, payment.Currency)
+	}
+
+	if err := refundStarPayment(bot, userID, chargeID); err != nil {
+		log.Printf("Ошибка возврата платежа %s: %v", chargeID, err)
+		return fmt.Sprintf("Telegram не принял возврат платежа %s, подробности в логах.", chargeID)
+	}
+	// Списывается то, что записано в платеже. Тариф из каталога нужен только для старых платежей,
+	// в которых зачисленное не записано; если его уже удалили, с баланса ничего не списывается
+	tariff, _ := tariffs.ByID(payment.TariffID)
+	if err := ledger.Refund(userID, chargeID, tariff); err != nil {
+		log.Printf("Ошибка списания возвращённого платежа %s: %v", chargeID, err)
+	}
+	log.Printf("Платёж %s пользователя %d возвращён (%d %s)", chargeID, userID, payment.Amount, payment.Currency)
+	return fmt.Sprintf("Платёж %s возвращён: %d ⭐ пользователю %d.", chargeID, payment.Amount, userID)
+}
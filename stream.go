@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamingInterpreter — толкователь, который умеет отдавать ответ по мере генерации.
+// onDelta получает очередной видимый фрагмент текста: блоки <think> уже вырезаны.
+type StreamingInterpreter interface {
+	Interpreter
+	InterpretStream(ctx context.Context, req InterpretRequest, onDelta func(delta string)) (Interpretation, error)
+}
+
+// Функция interpretStream толкует расклад потоково, если толкователь это поддерживает,
+// а иначе получает ответ целиком и передаёт его в onDelta одним фрагментом.
+func interpretStream(ctx context.Context, interp Interpreter, req InterpretRequest, onDelta func(string)) (Interpretation, error) {
+	if streaming, ok := interp.(StreamingInterpreter); ok {
+		return streaming.InterpretStream(ctx, req, onDelta)
+	}
+	result, err := interp.Interpret(ctx, req)
+	if err == nil {
+		onDelta(result.Text)
+	}
+	return result, err
+}
+
+// streamChunk — один фрагмент потокового ответа. Поля покрывают форматы,
+// которые встречаются на практике: SSE от OpenAI-совместимых completions и chat completions,
+// NDJSON от нативных /api/generate и /api/chat в Ollama.
+type streamChunk struct {
+	Choices []struct {
+		Text  string `json:"text"` // completions
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"` // chat completions
+	} `json:"choices"`
+	Response string          `json:"response"` // Ollama /api/generate
+	Message  *ChatMessage    `json:"message"`  // Ollama /api/chat
+	Done     bool            `json:"done"`     // Ollama: последний фрагмент
+	Error    json.RawMessage `json:"error"`    // Ошибка, переданная внутри потока
+}
+
+// text возвращает текст фрагмента независимо от формата.
+func (c streamChunk) text() string {
+	var b strings.Builder
+	for _, choice := range c.Choices {
+		b.WriteString(choice.Text)
+		b.WriteString(choice.Delta.Content)
+	}
+	b.WriteString(c.Response)
+	if c.Message != nil {
+		b.WriteString(c.Message.Content)
+	}
+	return b.String()
+}
+
+// Функция readStream читает поток SSE ("data: {...}") или NDJSON ("{...}" построчно)
+// и передаёт текст каждого фрагмента в onText.
+func readStream(body io.Reader, onText func(string)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// Пустые строки разделяют события SSE, строки с ":" — комментарии, event:/id: нам не нужны
+		if line == "" || strings.HasPrefix(line, ":") || strings.HasPrefix(line, "event:") || strings.HasPrefix(line, "id:") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if line == "[DONE]" {
+			return nil
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("ошибка декодирования фрагмента потока: %v", err)
+		}
+		if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+			return fmt.Errorf("модель вернула ошибку в потоке: %s", chunk.Error)
+		}
+		if text := chunk.text(); text != "" {
+			onText(text)
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Функция streamVisible читает поток модели, вырезает блоки <think> и передаёт
+// видимый текст в onDelta. Возвращает весь видимый текст ответа.
+func streamVisible(body io.Reader, onDelta func(string)) (string, error) {
+	var filter thinkFilter
+	var full strings.Builder
+	emit := func(visible string) {
+		if visible != "" {
+			full.WriteString(visible)
+			onDelta(visible)
+		}
+	}
+
+	err := readStream(body, func(text string) {
+		emit(filter.Write(text))
+	})
+	emit(filter.Flush())
+	return strings.TrimSpace(full.String()), err
+}
+
+// thinkFilter потоково вырезает блоки <think> ... </think>.
+// Теги могут быть разрезаны между фрагментами, поэтому возможное начало тега
+// придерживается до прихода следующего фрагмента.
+type thinkFilter struct {
+	inThink bool   // Сейчас внутри блока <think>
+	pending string // Необработанный хвост, который может оказаться началом тега
+}
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// Write принимает очередной фрагмент и возвращает текст, который можно показать.
+func (f *thinkFilter) Write(chunk string) string {
+	f.pending += chunk
+	var out strings.Builder
+	for {
+		if f.inThink {
+			i := strings.Index(f.pending, thinkCloseTag)
+			if i < 0 {
+				// Содержимое рассуждений отбрасываем, оставляя лишь возможное начало закрывающего тега
+				f.pending = f.pending[len(f.pending)-partialTagSuffix(f.pending, thinkCloseTag):]
+				return out.String()
+			}
+			f.pending = f.pending[i+len(thinkCloseTag):]
+			f.inThink = false
+			continue
+		}
+
+		i := strings.Index(f.pending, thinkOpenTag)
+		if i >= 0 {
+			out.WriteString(f.pending[:i])
+			f.pending = f.pending[i+len(thinkOpenTag):]
+			f.inThink = true
+			continue
+		}
+
+		keep := partialTagSuffix(f.pending, thinkOpenTag)
+		out.WriteString(f.pending[:len(f.pending)-keep])
+		f.pending = f.pending[len(f.pending)-keep:]
+		return out.String()
+	}
+}
+
+// Flush возвращает придержанный хвост в конце потока.
+// Незакрытый блок <think> целиком считается рассуждениями и отбрасывается.
+func (f *thinkFilter) Flush() string {
+	if f.inThink {
+		f.pending = ""
+		return ""
+	}
+	out := f.pending
+	f.pending = ""
+	return out
+}
+
+// Функция partialTagSuffix возвращает длину самого длинного окончания s,
+// которое совпадает с началом тега tag (но не со всем тегом).
+func partialTagSuffix(s, tag string) int {
+	for k := len(tag) - 1; k > 0; k-- {
+		if strings.HasSuffix(s, tag[:k]) {
+			return k
+		}
+	}
+	return 0
+}
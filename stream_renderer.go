@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramMessageLimit — максимальная длина текста одного сообщения Telegram.
+// Telegram считает длину в кодовых единицах UTF-16: эмодзи и другие символы вне BMP
+// занимают по две единицы, поэтому длину текста проверяем через utf16Len.
+const telegramMessageLimit = 4096
+
+// StreamRenderer показывает ответ модели по мере генерации: отправляет сообщение-заглушку
+// и редактирует его не чаще, чем раз в interval, чтобы не упереться в лимиты Telegram.
+type StreamRenderer struct {
+	bot       *tgbotapi.BotAPI
+	chatID    int64
+	messageID int           // Сообщение, которое редактируется по мере прихода текста
+	interval  time.Duration // Минимальный промежуток между правками
+
+	text     strings.Builder // Весь полученный видимый текст
+	shown    string          // Текст, который сейчас показан в сообщении
+	lastEdit time.Time
+}
+
+// Функция newStreamRenderer отправляет сообщение-заглушку и возвращает рендерер для него.
+func newStreamRenderer(bot *tgbotapi.BotAPI, chatID int64, placeholder string, interval time.Duration) *StreamRenderer {
+	return &StreamRenderer{
+		bot:       bot,
+		chatID:    chatID,
+		messageID: sendMessage(bot, chatID, placeholder),
+		interval:  interval,
+		shown:     placeholder,
+		lastEdit:  time.Now(),
+	}
+}
+
+// MessageID возвращает ID сообщения, в котором отображается ответ.
+func (r *StreamRenderer) MessageID() int {
+	return r.messageID
+}
+
+// Update добавляет очередной фрагмент текста и при необходимости обновляет сообщение.
+func (r *StreamRenderer) Update(delta string) {
+	r.text.WriteString(delta)
+	if time.Since(r.lastEdit) < r.interval {
+		return
+	}
+
+	// Пока ответ генерируется, показываем его начало с многоточием:
+	// разбивка на несколько сообщений делается только в Finish.
+	preview := truncateUTF16(strings.TrimSpace(r.text.String()), telegramMessageLimit-2)
+	r.edit(preview + " …")
+}
+
+// Finish показывает окончательный текст. Если он не помещается в одно сообщение,
+// остаток отправляется новыми сообщениями, ID которых возвращаются.
+func (r *StreamRenderer) Finish(final string) []int {
+	parts := splitMessage(final, telegramMessageLimit)
+	r.edit(parts[0])
+
+	var extra []int
+	for _, part := range parts[1:] {
+		extra = append(extra, sendMessage(r.bot, r.chatID, part))
+	}
+	return extra
+}
+
+// edit заменяет текст сообщения, если он изменился.
+func (r *StreamRenderer) edit(text string) {
+	r.lastEdit = time.Now()
+	if r.messageID == 0 || text == "" || text == r.shown {
+		return
+	}
+	if _, err := r.bot.Request(tgbotapi.NewEditMessageText(r.chatID, r.messageID, text)); err != nil {
+		log.Printf("Ошибка обновления сообщения %d: %v", r.messageID, err)
+		return
+	}
+	r.shown = text
+}
+
+// Функция utf16Len возвращает длину текста в кодовых единицах UTF-16, как её считает Telegram.
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16Units(r)
+	}
+	return n
+}
+
+// Функция utf16Units возвращает число кодовых единиц UTF-16 для руны:
+// символы вне BMP кодируются суррогатной парой.
+func utf16Units(r rune) int {
+	if r > 0xFFFF {
+		return 2
+	}
+	return 1
+}
+
+// Функция truncateUTF16 возвращает самое длинное начало текста, которое занимает
+// не больше limit кодовых единиц UTF-16. Текст режется только по границам рун.
+func truncateUTF16(text string, limit int) string {
+	n := 0
+	for i, r := range text {
+		n += utf16Units(r)
+		if n > limit {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// Функция splitMessage разбивает текст на части не длиннее limit кодовых единиц UTF-16,
+// стараясь резать по границам абзацев и строк.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	for utf16Len(text) > limit {
+		chunk := truncateUTF16(text, limit)
+		cut := len(chunk)
+		if i := strings.LastIndex(chunk, "\n\n"); i > 0 {
+			cut = i
+		} else if i := strings.LastIndex(chunk, "\n"); i > 0 {
+			cut = i
+		}
+		parts = append(parts, strings.TrimSpace(text[:cut]))
+		text = text[cut:]
+	}
+	return append(parts, strings.TrimSpace(text))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Эмодзи занимают в UTF-16 две единицы, и части должны укладываться в лимит Telegram именно по ним.
+func TestSplitMessageCountsUTF16(t *testing.T) {
+	text := strings.Repeat("🔮", 3000) + "\n\n" + strings.Repeat("карта ", 500)
+	parts := splitMessage(text, telegramMessageLimit)
+	if len(parts) < 2 {
+		t.Fatalf("ожидалось несколько частей, получено %d", len(parts))
+	}
+	for i, part := range parts {
+		if n := utf16Len(part); n > telegramMessageLimit {
+			t.Errorf("часть %d: %d единиц UTF-16, лимит %d", i, n, telegramMessageLimit)
+		}
+	}
+	if got := strings.Join(parts, ""); strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(strings.ReplaceAll(text, "\n", ""), " ", "") {
+		t.Error("при разбивке потерялся текст")
+	}
+}
+
+// Обрезка не должна разрезать суррогатную пару.
+func TestTruncateUTF16KeepsRunes(t *testing.T) {
+	if got := truncateUTF16("а🔮б", 2); got != "а" {
+		t.Errorf("truncateUTF16 = %q, ожидалось %q", got, "а")
+	}
+	if got := truncateUTF16("а🔮б", 3); got != "а🔮" {
+		t.Errorf("truncateUTF16 = %q, ожидалось %q", got, "а🔮")
+	}
+}
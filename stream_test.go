@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThinkFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   string
+	}{
+		{"без рассуждений", []string{"Шут ", "говорит ", "о начале."}, "Шут говорит о начале."},
+		{"блок целиком в одном фрагменте", []string{"<think>рассуждаю</think>Ответ"}, "Ответ"},
+		{"разрезан открывающий тег", []string{"<th", "ink>рассуждаю</think>Ответ"}, "Ответ"},
+		{"открывающий тег по одному символу", strings.Split("<think>x</think>Ответ", ""), "Ответ"},
+		{"разрезан закрывающий тег", []string{"<think>рассуждаю</th", "ink>Ответ"}, "Ответ"},
+		{"закрывающий тег на границе", []string{"<think>рассуждаю<", "/think>", "Ответ"}, "Ответ"},
+		{"текст до и после блока", []string{"Начало <think>скрыто</think> конец"}, "Начало  конец"},
+		{"несколько блоков", []string{"А<think>1</think>Б<thi", "nk>2</think>В"}, "АБВ"},
+		{"хвостовой < не тег", []string{"a < b", " <"}, "a < b <"},
+		{"похоже на тег, но не тег", []string{"<thi", "s is fine>"}, "<this is fine>"},
+		{"незакрытый блок в конце потока", []string{"Ответ<think>рассуждения без ", "конца"}, "Ответ"},
+		{"незакрытый блок с началом закрывающего тега", []string{"<think>рассуждения</thi"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var f thinkFilter
+			var got strings.Builder
+			for _, chunk := range tt.chunks {
+				got.WriteString(f.Write(chunk))
+			}
+			got.WriteString(f.Flush())
+			if got.String() != tt.want {
+				t.Errorf("получено %q, ожидалось %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+// Возможное начало тега придерживается до следующего фрагмента, остальной текст отдаётся сразу.
+func TestThinkFilterHoldsOnlyPartialTag(t *testing.T) {
+	var f thinkFilter
+	if got := f.Write("Ответ <thi"); got != "Ответ " {
+		t.Errorf("Write = %q, ожидалось %q", got, "Ответ ")
+	}
+	if got := f.Write("s"); got != "<this" {
+		t.Errorf("после продолжения не тега Write = %q, ожидалось %q", got, "<this")
+	}
+	if got := f.Flush(); got != "" {
+		t.Errorf("Flush = %q, придержанного текста быть не должно", got)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Виды тарифов.
+const (
+	TariffReadings     = "readings"     // Пакет раскладов: добавляет Readings раскладов на баланс
+	TariffSubscription = "subscription" // Подписка: безлимитные расклады на Days дней
+)
+
+// starsCurrency — код валюты Telegram Stars.
+const starsCurrency = "XTR"
+
+// Tariff — платный тариф из каталога.
+type Tariff struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Kind        string `json:"kind"`               // TariffReadings или TariffSubscription
+	Readings    int    `json:"readings,omitempty"` // Сколько раскладов даёт пакет
+	Days        int    `json:"days,omitempty"`     // На сколько дней действует подписка
+	Price       int    `json:"price,omitempty"`    // Цена в минимальных единицах валюты каталога (копейках), 0 — не продаётся за деньги
+	Stars       int    `json:"stars,omitempty"`    // Цена в Telegram Stars, 0 — не продаётся за звёзды
+}
+
+// TariffCatalog — каталог тарифов, загружается из tariffs.json.
+type TariffCatalog struct {
+	Currency  string   `json:"currency"`   // Валюта цен, код ISO 4217, например "RUB"
+	FreeDaily int      `json:"free_daily"` // Сколько бесплатных раскладов в день у каждого пользователя
+	Tariffs   []Tariff `json:"tariffs"`    // Тарифы в порядке показа
+	// Ограничения частоты запросов к модели по уровням: ReadingFree, ReadingPaid, ReadingSubscription.
+	// Уровень без ограничения не ограничивается.
+	RateLimits map[string]RateLimit `json:"rate_limits,omitempty"`
+}
+
+// Функция loadTariffs загружает и проверяет каталог тарифов из JSON-файла.
+func loadTariffs(filename string) (*TariffCatalog, error) {
+	file, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var catalog TariffCatalog
+	if err := json.Unmarshal(file, &catalog); err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %v", filename, err)
+	}
+	if len(catalog.Currency) != 3 {
+		return nil, fmt.Errorf("некорректная валюта %q", catalog.Currency)
+	}
+	if catalog.FreeDaily < 0 {
+		return nil, fmt.Errorf("отрицательное число бесплатных раскладов")
+	}
+	for tier, limit := range catalog.RateLimits {
+		switch {
+		case tier != ReadingFree && tier != ReadingPaid && tier != ReadingSubscription:
+			return nil, fmt.Errorf("ограничение для неизвестного уровня %q", tier)
+		case limit.Burst <= 0 || limit.Every <= 0:
+			return nil, fmt.Errorf("ограничение уровня %s: нужны положительные burst и every", tier)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for i, tariff := range catalog.Tariffs {
+		switch {
+		case tariff.ID == "" || strings.Contains(tariff.ID, ":"):
+			return nil, fmt.Errorf("тариф №%d: некорректный id %q", i+1, tariff.ID)
+		case ids[tariff.ID]:
+			return nil, fmt.Errorf("тариф %s описан дважды", tariff.ID)
+		case tariff.Title == "" || tariff.Description == "":
+			return nil, fmt.Errorf("тариф %s: не заданы название или описание", tariff.ID)
+		case tariff.Price < 0 || tariff.Stars < 0 || tariff.Price == 0 && tariff.Stars == 0:
+			return nil, fmt.Errorf("тариф %s: нужна положительная цена в рублях или в звёздах", tariff.ID)
+		case tariff.Kind == TariffReadings && tariff.Readings <= 0:
+			return nil, fmt.Errorf("тариф %s: не задано число раскладов", tariff.ID)
+		case tariff.Kind == TariffSubscription && tariff.Days <= 0:
+			return nil, fmt.Errorf("тариф %s: не задан срок подписки", tariff.ID)
+		case tariff.Kind != TariffReadings && tariff.Kind != TariffSubscription:
+			return nil, fmt.Errorf("тариф %s: неизвестный вид %q", tariff.ID, tariff.Kind)
+		}
+		ids[tariff.ID] = true
+	}
+	return &catalog, nil
+}
+
+// ByID находит тариф по идентификатору.
+func (c *TariffCatalog) ByID(id string) (Tariff, bool) {
+	for _, tariff := range c.Tariffs {
+		if tariff.ID == id {
+			return tariff, true
+		}
+	}
+	return Tariff{}, false
+}
+
+// PriceIn возвращает цену тарифа в валюте currency: в валюте каталога или в звёздах (XTR).
+func (c *TariffCatalog) PriceIn(tariff Tariff, currency string) (int, bool) {
+	switch {
+	case currency == starsCurrency && tariff.Stars > 0:
+		return tariff.Stars, true
+	case currency == c.Currency && tariff.Price > 0:
+		return tariff.Price, true
+	}
+	return 0, false
+}
+
+// FormatPrice записывает цену из минимальных единиц валюты для пользователя, например "399 ₽".
+func (c *TariffCatalog) FormatPrice(amount int) string {
+	value := fmt.Sprintf("%d", amount/100)
+	if kopecks := amount % 100; kopecks != 0 {
+		value = fmt.Sprintf("%d,%02d", amount/100, kopecks)
+	}
+	if c.Currency == "RUB" {
+		return value + " ₽"
+	}
+	return value + " " + c.Currency
+}